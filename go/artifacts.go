@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Destino de los archivos de depuración (capturas, imágenes de captcha).
+// Permite sacarlos de contenedores efímeros
+type ArtifactStore interface {
+	Put(name string, data []byte) error
+}
+
+// Guarda los archivos en un directorio local (vacío = directorio actual)
+type fileArtifactStore struct {
+	dir string
+}
+
+func newFileArtifactStore(dir string) *fileArtifactStore {
+	return &fileArtifactStore{dir: dir}
+}
+
+func (f *fileArtifactStore) Put(name string, data []byte) error {
+	if f.dir != "" {
+		if err := os.MkdirAll(f.dir, 0755); err != nil {
+			return fmt.Errorf("error creando directorio %s: %v", f.dir, err)
+		}
+	}
+	return os.WriteFile(filepath.Join(f.dir, name), data, 0644)
+}
+
+func (f *fileArtifactStore) Delete(name string) error {
+	return os.Remove(filepath.Join(f.dir, name))
+}
+
+// Destinos que permiten borrar un archivo ya guardado
+type artifactDeleter interface {
+	Delete(name string) error
+}
+
+// Limita el tamaño total de los archivos guardados en la ejecución. Al
+// superar maxBytes se borran los más antiguos si el destino lo permite; si
+// no, se omiten los nuevos con una advertencia
+type cappedArtifactStore struct {
+	inner    ArtifactStore
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries []artifactEntry // en orden de escritura
+	total   int64
+	warned  bool
+}
+
+type artifactEntry struct {
+	name string
+	size int64
+}
+
+func newCappedArtifactStore(inner ArtifactStore, maxBytes int64) *cappedArtifactStore {
+	return &cappedArtifactStore{inner: inner, maxBytes: maxBytes}
+}
+
+func (c *cappedArtifactStore) Put(name string, data []byte) error {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		log.Printf("ADVERTENCIA: se omite %s (%d bytes), supera el límite de %d bytes", name, size, c.maxBytes)
+		return nil
+	}
+
+	// El espacio se reserva con el candado tomado; el borrado y la escritura,
+	// que son disco o red, se hacen fuera para no frenar a los demás
+	c.mu.Lock()
+
+	// Sobrescribir un archivo ya guardado libera su tamaño anterior
+	for i, entry := range c.entries {
+		if entry.name == name {
+			c.total -= entry.size
+			c.entries = append(c.entries[:i], c.entries[i+1:]...)
+			break
+		}
+	}
+
+	deleter, canDelete := c.inner.(artifactDeleter)
+	if c.total+size > c.maxBytes && !canDelete {
+		if !c.warned {
+			log.Printf("ADVERTENCIA: se alcanzó el límite de %d bytes de archivos de depuración; no se guardarán más", c.maxBytes)
+			c.warned = true
+		}
+		c.mu.Unlock()
+		return nil
+	}
+	var evicted []string
+	for c.total+size > c.maxBytes && len(c.entries) > 0 {
+		oldest := c.entries[0]
+		evicted = append(evicted, oldest.name)
+		c.entries = c.entries[1:]
+		c.total -= oldest.size
+	}
+	c.entries = append(c.entries, artifactEntry{name, size})
+	c.total += size
+	c.mu.Unlock()
+
+	for _, old := range evicted {
+		if err := deleter.Delete(old); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error borrando %s: %v", old, err)
+		}
+	}
+	if err := c.inner.Put(name, data); err != nil {
+		c.release(name, size)
+		return err
+	}
+	return nil
+}
+
+// Devolver el espacio reservado para un archivo que no se pudo escribir
+func (c *cappedArtifactStore) release(name string, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.entries) - 1; i >= 0; i-- {
+		if c.entries[i].name == name && c.entries[i].size == size {
+			c.entries = append(c.entries[:i], c.entries[i+1:]...)
+			c.total -= size
+			return
+		}
+	}
+}
+
+// Escribe los archivos en un grupo fijo de goroutines para que los workers
+// no esperen al disco o a la red. Con la cola llena Put espera a que se
+// libere espacio; Close espera a que terminen las escrituras pendientes y
+// puede llamarse más de una vez
+type asyncArtifactStore struct {
+	inner     ArtifactStore
+	ch        chan artifactWrite
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+type artifactWrite struct {
+	name string
+	data []byte
+}
+
+func newAsyncArtifactStore(inner ArtifactStore, writers, queue int) *asyncArtifactStore {
+	if writers <= 0 {
+		writers = 1
+	}
+	if queue < 0 {
+		queue = 0
+	}
+	a := &asyncArtifactStore{inner: inner, ch: make(chan artifactWrite, queue)}
+	for i := 0; i < writers; i++ {
+		a.wg.Add(1)
+		go a.run()
+	}
+	return a
+}
+
+func (a *asyncArtifactStore) run() {
+	defer a.wg.Done()
+	for w := range a.ch {
+		if err := a.inner.Put(w.name, w.data); err != nil {
+			log.Printf("Error guardando %s: %v", w.name, err)
+		}
+	}
+}
+
+func (a *asyncArtifactStore) Put(name string, data []byte) error {
+	a.ch <- artifactWrite{name, data}
+	return nil
+}
+
+func (a *asyncArtifactStore) Close() error {
+	a.closeOnce.Do(func() { close(a.ch) })
+	a.wg.Wait()
+	return nil
+}
+
+// Sube los archivos a un bucket compatible con S3 (AWS, MinIO, R2...)
+// usando firma SigV4 y direcciones tipo path (endpoint/bucket/clave)
+type s3ArtifactStore struct {
+	endpoint  string
+	bucket    string
+	region    string
+	prefix    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func newS3ArtifactStore(endpoint, bucket, region, prefix, accessKey, secretKey string) *s3ArtifactStore {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &s3ArtifactStore{
+		endpoint:  strings.TrimRight(endpoint, "/"),
+		bucket:    bucket,
+		region:    region,
+		prefix:    prefix,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (s *s3ArtifactStore) Put(name string, data []byte) error {
+	key := path.Join(s.prefix, name)
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	target := fmt.Sprintf("%s/%s/%s", s.endpoint, url.PathEscape(s.bucket), strings.Join(segments, "/"))
+
+	req, err := http.NewRequest(http.MethodPut, target, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error creando petición S3: %v", err)
+	}
+	s.sign(req, data, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error subiendo %s a S3: %v", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("S3 respondió %d al subir %s: %s", resp.StatusCode, key, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Firmar la petición con AWS Signature Version 4
+func (s *s3ArtifactStore) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
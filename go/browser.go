@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/chromedp"
+)
+
+// Versiones mayores de Chrome con las que se ha probado el protocolo de chromedp
+const (
+	minChromeMajor = 115
+	maxChromeMajor = 140
+)
+
+// Registrar la versión de Chrome (una sola vez por ejecución) y advertir si
+// está fuera del rango conocido; los fallos por incompatibilidad son opacos
+func (s *Scraper) checkBrowserVersion(ctx context.Context) {
+	s.versionOnce.Do(func() {
+		var product string
+		err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			_, product, _, _, _, err = browser.GetVersion().Do(ctx)
+			return err
+		}))
+		if err != nil {
+			log.Printf("No se pudo obtener la versión de Chrome: %v", err)
+			return
+		}
+
+		s.browserVersion = product
+		log.Printf("Versión de Chrome: %s", product)
+
+		major := chromeMajorVersion(product)
+		if major == 0 {
+			log.Printf("ADVERTENCIA: no se reconoce la versión de Chrome %q", product)
+		} else if major < minChromeMajor || major > maxChromeMajor {
+			log.Printf("ADVERTENCIA: Chrome %d está fuera del rango probado (%d-%d); pueden fallar las consultas",
+				major, minChromeMajor, maxChromeMajor)
+		}
+	})
+}
+
+// Versión mayor a partir del producto, ej. "HeadlessChrome/122.0.6261.94" -> 122
+func chromeMajorVersion(product string) int {
+	_, version, ok := strings.Cut(product, "/")
+	if !ok {
+		return 0
+	}
+	majorStr, _, _ := strings.Cut(version, ".")
+	major, err := strconv.Atoi(majorStr)
+	if err != nil {
+		return 0
+	}
+	return major
+}
+
+// Versión de Chrome detectada, para el resumen y reportes de errores
+func (s *Scraper) BrowserVersion() string {
+	return s.browserVersion
+}
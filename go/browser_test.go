@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Pestaña de Chrome headless para las pruebas que necesitan navegador. Usa
+// CHROME_PATH si está definido; sin Chrome la prueba se omite
+func newTestBrowser(t *testing.T) context.Context {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("prueba con navegador omitida con -short")
+	}
+	opts := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.NoSandbox)
+	if path := os.Getenv("CHROME_PATH"); path != "" {
+		opts = append(opts, chromedp.ExecPath(path))
+	}
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	t.Cleanup(func() {
+		cancel()
+		cancelAlloc()
+	})
+	// El primer Run inicia Chrome (como launchBrowser); su contexto no debe
+	// tener timeout
+	if err := chromedp.Run(ctx, chromedp.Navigate("about:blank")); err != nil {
+		t.Skipf("Chrome no disponible: %v", err)
+	}
+	return ctx
+}
+
+// Servidor con las páginas de testdata
+func newFixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// Copia local de la consulta de la DIAN: /consulta sirve testdata/dian.html
+// con el código HTTP del parámetro "status" (200 si no está), /redirigir
+// redirige a otra dirección y el resto de rutas sirven los archivos de
+// testdata
+func newFakeDIAN(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir("testdata")))
+	mux.HandleFunc("/consulta", func(w http.ResponseWriter, r *http.Request) {
+		page, err := os.ReadFile(filepath.Join("testdata", "dian.html"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		status := http.StatusOK
+		if s, err := strconv.Atoi(r.URL.Query().Get("status")); err == nil {
+			status = s
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		w.Write(page)
+	})
+	// Redirección al destino del parámetro "a", como el paso a un login
+	mux.HandleFunc("/redirigir", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Query().Get("a"), http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// Configuración para consultar la copia local: tiempos cortos y los archivos
+// de depuración en memoria
+func browserTestConfig() Config {
+	config := getDefaultConfig()
+	config.APIKey = "clave"
+	config.ArtifactStore = newMemoryArtifactStore()
+	config.ExtractionRetries = 0
+	config.TimeoutConfig.PageLoad = 10 * time.Second
+	config.TimeoutConfig.DataExtraction = 3 * time.Second
+	config.TimeoutConfig.Captcha = 10 * time.Second
+	return config
+}
+
+// Scraper que consulta la copia local con el escenario indicado (ver
+// testdata/dian.html)
+func newBrowserScraper(t *testing.T, config Config, srv *httptest.Server, query string) *Scraper {
+	t.Helper()
+	config.ScreenshotDir = t.TempDir()
+	s, err := NewScraper(config)
+	if err != nil {
+		t.Fatalf("NewScraper: %v", err)
+	}
+	t.Cleanup(s.Close)
+	s.consultURL = srv.URL + "/consulta?" + query
+	return s
+}
+
+// 2captcha falso para un scraper de prueba: acepta cada envío y entrega
+// answer por el servidor de pingback del scraper, sin consultar res.php
+func withFakeCaptcha(t *testing.T, s *Scraper, answer string) *fakeTwoCaptcha {
+	t.Helper()
+	pingback, err := startPingbackServer("127.0.0.1:0", "t0k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.pingback = pingback
+	s.pingbackCallback = "http://127.0.0.1/pingback?token=t0k"
+
+	var ids atomic.Int64
+	fake, client := newFakeTwoCaptcha(t, func(form url.Values) string {
+		if form.Get("action") != "" {
+			// reportbad / reportgood
+			return `{"status":1,"request":"OK_REPORT_RECORDED"}`
+		}
+		id := strconv.FormatInt(ids.Add(1), 10)
+		pingback.deliver(id, answer)
+		return `{"status":1,"request":"` + id + `"}`
+	})
+	s.captcha = client
+	return fake
+}
+
+func TestChromeMajorVersion(t *testing.T) {
+	tests := []struct {
+		product string
+		want    int
+	}{
+		{"HeadlessChrome/122.0.6261.94", 122},
+		{"Chrome/140.0.7339.80", 140},
+		{"HeadlessChrome/99", 99},
+		{"HeadlessChrome", 0},
+		{"Chrome/beta.1", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := chromeMajorVersion(tt.product); got != tt.want {
+			t.Errorf("chromeMajorVersion(%q) = %d, se esperaba %d", tt.product, got, tt.want)
+		}
+	}
+}
+
+func TestCheckBrowserVersion(t *testing.T) {
+	ctx := newTestBrowser(t)
+	s, err := NewScraper(browserTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.checkBrowserVersion(ctx)
+	version := s.BrowserVersion()
+	if chromeMajorVersion(version) == 0 {
+		t.Errorf("BrowserVersion() = %q, se esperaba un producto como \"HeadlessChrome/122.0\"", version)
+	}
+}
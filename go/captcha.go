@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Formas de enviar la imagen a in.php
+const (
+	captchaSubmitBase64    = "base64" // imagen en base64 dentro del formulario
+	captchaSubmitMultipart = "post"   // PNG como archivo multipart; 2captcha puede rechazar el base64 de imágenes grandes
+)
+
+// Respuesta de in.php y res.php con json=1
+type CaptchaResponse struct {
+	Status  int    `json:"status"`
+	Request string `json:"request"`
+}
+
+// Cliente del API de 2captcha (in.php / res.php). Las URLs y el cliente HTTP
+// se pueden reemplazar, por ejemplo para apuntar a un servidor de pruebas
+type TwoCaptchaClient struct {
+	APIKey     string
+	SubmitURL  string
+	ResultURL  string
+	SoftID     string
+	HTTPClient *http.Client
+	// Envío de las imágenes (captchaSubmitBase64 o captchaSubmitMultipart;
+	// vacío = base64)
+	SubmitMethod string
+}
+
+// Cliente HTTP para 2captcha que reutiliza conexiones (keep-alive): con
+// muchos captchas en curso cada consulta a res.php toma una conexión libre en
+// lugar de abrir otra, y como máximo quedan maxIdle conexiones inactivas
+func newCaptchaHTTPClient(maxIdle int, timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if maxIdle > 0 {
+		transport.MaxIdleConns = maxIdle
+		transport.MaxIdleConnsPerHost = maxIdle
+	}
+	transport.IdleConnTimeout = 90 * time.Second
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+func NewTwoCaptchaClient(apiKey string) *TwoCaptchaClient {
+	return &TwoCaptchaClient{
+		APIKey:     apiKey,
+		SubmitURL:  twoCaptchaAPIURL,
+		ResultURL:  twoCaptchaResURL,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Enviar una imagen y devolver el id del captcha. Si pingbackURL no está
+// vacío, 2captcha envía ahí la respuesta
+func (c *TwoCaptchaClient) Submit(img []byte, pingbackURL string) (string, error) {
+	formData := url.Values{}
+	if c.SubmitMethod == captchaSubmitMultipart {
+		formData.Set("method", "post")
+		return c.submit(formData, img, pingbackURL)
+	}
+	formData.Set("method", "base64")
+	formData.Set("body", base64.StdEncoding.EncodeToString(img))
+	return c.submit(formData, nil, pingbackURL)
+}
+
+// Enviar un captcha de audio (mp3) en el idioma indicado (ej. "es")
+func (c *TwoCaptchaClient) SubmitAudio(audio []byte, lang, pingbackURL string) (string, error) {
+	formData := url.Values{}
+	formData.Set("method", "audio")
+	formData.Set("body", base64.StdEncoding.EncodeToString(audio))
+	if lang != "" {
+		formData.Set("lang", lang)
+	}
+	return c.submit(formData, nil, pingbackURL)
+}
+
+// Enviar el formulario a in.php; con file se envía como multipart, con la
+// imagen en el campo "file"
+func (c *TwoCaptchaClient) submit(formData url.Values, file []byte, pingbackURL string) (string, error) {
+	formData.Set("key", c.APIKey)
+	formData.Set("json", "1")
+	if c.SoftID != "" {
+		formData.Set("soft_id", c.SoftID)
+	}
+	if pingbackURL != "" {
+		formData.Set("pingback", pingbackURL)
+	}
+
+	req, err := newSubmitRequest(c.SubmitURL, formData, file)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error enviando captcha a 2captcha: %v", err)
+	}
+	captchaResp, err := decodeCaptchaResponse(resp)
+	if err != nil {
+		return "", err
+	}
+	if captchaResp.Status != 1 {
+		return "", fmt.Errorf("error en respuesta de 2captcha: %s", captchaResp.Request)
+	}
+	return captchaResp.Request, nil
+}
+
+// Consultar una vez el resultado de un captcha. ready es false mientras
+// 2captcha responde CAPCHA_NOT_READY
+func (c *TwoCaptchaClient) Poll(id string) (answer string, ready bool, err error) {
+	captchaResp, err := c.get(url.Values{"action": {"get"}, "id": {id}})
+	if err != nil {
+		return "", false, err
+	}
+	if captchaResp.Status == 1 {
+		return captchaResp.Request, true, nil
+	}
+	if captchaResp.Request == "CAPCHA_NOT_READY" {
+		return "", false, nil
+	}
+	return "", false, captchaAPIError(captchaResp.Request)
+}
+
+// Código de error devuelto por 2captcha (ERROR_CAPTCHA_UNSOLVABLE...), a
+// diferencia de los fallos de red, que se pueden reintentar
+type captchaAPIError string
+
+func (e captchaAPIError) Error() string {
+	return fmt.Sprintf("error resolviendo captcha: %s", string(e))
+}
+
+// 2captcha no pudo leer la imagen; otra captura puede funcionar
+func isCaptchaUnsolvable(err error) bool {
+	var apiErr captchaAPIError
+	return errors.As(err, &apiErr) && apiErr == "ERROR_CAPTCHA_UNSOLVABLE"
+}
+
+// Saldo de la cuenta en USD
+func (c *TwoCaptchaClient) Balance() (float64, error) {
+	captchaResp, err := c.get(url.Values{"action": {"getbalance"}})
+	if err != nil {
+		return 0, err
+	}
+	if captchaResp.Status != 1 {
+		return 0, fmt.Errorf("error consultando saldo de 2captcha: %s", captchaResp.Request)
+	}
+	balance, err := strconv.ParseFloat(captchaResp.Request, 64)
+	if err != nil {
+		return 0, fmt.Errorf("saldo de 2captcha inválido %q: %v", captchaResp.Request, err)
+	}
+	return balance, nil
+}
+
+// Reportar si la respuesta de un captcha fue correcta; los reportes
+// negativos devuelven el costo del captcha
+func (c *TwoCaptchaClient) Report(id string, good bool) error {
+	action := "reportbad"
+	if good {
+		action = "reportgood"
+	}
+	captchaResp, err := c.get(url.Values{"action": {action}, "id": {id}})
+	if err != nil {
+		return err
+	}
+	if captchaResp.Status != 1 {
+		return fmt.Errorf("error reportando captcha %s: %s", id, captchaResp.Request)
+	}
+	return nil
+}
+
+// Petición a in.php: formulario urlencoded o, si hay archivo, multipart
+func newSubmitRequest(submitURL string, formData url.Values, file []byte) (*http.Request, error) {
+	if file == nil {
+		req, err := http.NewRequest(http.MethodPost, submitURL, strings.NewReader(formData.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("error creando envío a 2captcha: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, values := range formData {
+		for _, value := range values {
+			if err := w.WriteField(name, value); err != nil {
+				return nil, fmt.Errorf("error creando envío a 2captcha: %v", err)
+			}
+		}
+	}
+	part, err := w.CreateFormFile("file", "captcha.png")
+	if err != nil {
+		return nil, fmt.Errorf("error creando envío a 2captcha: %v", err)
+	}
+	if _, err := part.Write(file); err != nil {
+		return nil, fmt.Errorf("error creando envío a 2captcha: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("error creando envío a 2captcha: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, submitURL, &body)
+	if err != nil {
+		return nil, fmt.Errorf("error creando envío a 2captcha: %v", err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req, nil
+}
+
+func (c *TwoCaptchaClient) get(params url.Values) (CaptchaResponse, error) {
+	params.Set("key", c.APIKey)
+	params.Set("json", "1")
+	resp, err := c.HTTPClient.Get(c.ResultURL + "?" + params.Encode())
+	if err != nil {
+		return CaptchaResponse{}, fmt.Errorf("error consultando 2captcha: %v", err)
+	}
+	return decodeCaptchaResponse(resp)
+}
+
+func decodeCaptchaResponse(resp *http.Response) (CaptchaResponse, error) {
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return CaptchaResponse{}, fmt.Errorf("error leyendo respuesta de 2captcha: %v", err)
+	}
+	var captchaResp CaptchaResponse
+	if err := json.Unmarshal(body, &captchaResp); err != nil {
+		return CaptchaResponse{}, fmt.Errorf("error parseando respuesta de 2captcha: %v", err)
+	}
+	return captchaResp, nil
+}
+
+// Caché acotada de respuestas por hash de la imagen; al llenarse descarta la
+// más antigua. Un *captchaCache nil no guarda nada
+type captchaCache struct {
+	mu      sync.Mutex
+	max     int
+	answers map[string]string
+	order   []string // claves en orden de llegada
+}
+
+func newCaptchaCache(max int) *captchaCache {
+	return &captchaCache{max: max, answers: make(map[string]string, max)}
+}
+
+func captchaImageKey(img []byte) string {
+	sum := sha256.Sum256(img)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *captchaCache) get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	answer, ok := c.answers[key]
+	return answer, ok
+}
+
+func (c *captchaCache) put(key, answer string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.answers[key]; ok {
+		c.answers[key] = answer
+		return
+	}
+	if len(c.order) >= c.max {
+		delete(c.answers, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.answers[key] = answer
+	c.order = append(c.order, key)
+}
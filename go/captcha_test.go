@@ -0,0 +1,470 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Servidor falso de 2captcha: responde con fn y guarda el formulario de cada
+// petición
+type fakeTwoCaptcha struct {
+	mu       sync.Mutex
+	requests []url.Values
+	fn       func(form url.Values) string
+}
+
+func newFakeTwoCaptcha(t *testing.T, fn func(form url.Values) string) (*fakeTwoCaptcha, *TwoCaptchaClient) {
+	t.Helper()
+	fake := &fakeTwoCaptcha{fn: fn}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+			t.Errorf("formulario inválido: %v", err)
+		}
+		fake.mu.Lock()
+		fake.requests = append(fake.requests, r.Form)
+		fake.mu.Unlock()
+		w.Write([]byte(fn(r.Form)))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewTwoCaptchaClient("clave")
+	client.SubmitURL = srv.URL + "/in.php"
+	client.ResultURL = srv.URL + "/res.php"
+	return fake, client
+}
+
+func (f *fakeTwoCaptcha) last() url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.requests) == 0 {
+		return nil
+	}
+	return f.requests[len(f.requests)-1]
+}
+
+func (f *fakeTwoCaptcha) all() []url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]url.Values(nil), f.requests...)
+}
+
+func TestSubmitSoftIDAndPingback(t *testing.T) {
+	tests := []struct {
+		name         string
+		softID       string
+		pingback     string
+		wantSoftID   string
+		wantPingback string
+	}{
+		{"sin opciones", "", "", "", ""},
+		{"soft id", "1234", "", "1234", ""},
+		{"pingback", "", "https://ejemplo.com/pb?token=t", "", "https://ejemplo.com/pb?token=t"},
+		{"ambos", "1234", "https://ejemplo.com/pb", "1234", "https://ejemplo.com/pb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeTwoCaptcha(t, func(url.Values) string { return `{"status":1,"request":"99"}` })
+			client.SoftID = tt.softID
+
+			id, err := client.Submit([]byte("png"), tt.pingback)
+			if err != nil {
+				t.Fatalf("Submit: %v", err)
+			}
+			if id != "99" {
+				t.Errorf("id = %q, se esperaba \"99\"", id)
+			}
+			form := fake.last()
+			if got := form.Get("soft_id"); got != tt.wantSoftID {
+				t.Errorf("soft_id = %q, se esperaba %q", got, tt.wantSoftID)
+			}
+			if got := form.Get("pingback"); got != tt.wantPingback {
+				t.Errorf("pingback = %q, se esperaba %q", got, tt.wantPingback)
+			}
+			if form.Get("key") != "clave" || form.Get("json") != "1" {
+				t.Errorf("faltan key/json en el envío: %v", form)
+			}
+		})
+	}
+}
+
+// Imagen PNG en blanco de w x h píxeles
+func pngImage(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Con SubmitMethod post la imagen va como archivo multipart; el audio se
+// sigue enviando en base64
+func TestSubmitMethod(t *testing.T) {
+	img := pngImage(t, 40, 20)
+	tests := []struct {
+		name          string
+		method        string
+		audio         bool
+		wantMultipart bool
+		wantMethod    string
+	}{
+		{name: "base64 por defecto", method: "", wantMethod: "base64"},
+		{name: "base64", method: captchaSubmitBase64, wantMethod: "base64"},
+		{name: "multipart", method: captchaSubmitMultipart, wantMultipart: true, wantMethod: "post"},
+		{name: "audio con multipart", method: captchaSubmitMultipart, audio: true, wantMethod: "audio"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var form url.Values
+			var file []byte
+			var multipartReq bool
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				multipartReq = strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+				if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+					t.Errorf("formulario inválido: %v", err)
+				}
+				form = r.Form
+				if f, _, err := r.FormFile("file"); err == nil {
+					file, _ = io.ReadAll(f)
+					f.Close()
+				}
+				w.Write([]byte(`{"status":1,"request":"7"}`))
+			}))
+			defer srv.Close()
+			client := NewTwoCaptchaClient("clave")
+			client.SubmitURL = srv.URL + "/in.php"
+			client.SubmitMethod = tt.method
+
+			var id string
+			var err error
+			if tt.audio {
+				id, err = client.SubmitAudio([]byte("mp3"), "es", "http://127.0.0.1/pingback")
+			} else {
+				id, err = client.Submit(img, "http://127.0.0.1/pingback")
+			}
+			if err != nil || id != "7" {
+				t.Fatalf("envío = %q, %v", id, err)
+			}
+			if multipartReq != tt.wantMultipart {
+				t.Errorf("multipart = %v, se esperaba %v", multipartReq, tt.wantMultipart)
+			}
+			if got := form.Get("method"); got != tt.wantMethod {
+				t.Errorf("method = %q, se esperaba %q", got, tt.wantMethod)
+			}
+			// Los demás campos llegan igual por cualquiera de los dos caminos
+			for field, want := range map[string]string{"key": "clave", "json": "1", "pingback": "http://127.0.0.1/pingback"} {
+				if got := form.Get(field); got != want {
+					t.Errorf("%s = %q, se esperaba %q", field, got, want)
+				}
+			}
+			if tt.wantMultipart {
+				if !bytes.Equal(file, img) {
+					t.Errorf("el archivo enviado (%d bytes) no es la imagen (%d bytes)", len(file), len(img))
+				}
+				if form.Get("body") != "" {
+					t.Error("el envío multipart incluye también la imagen en base64")
+				}
+			} else if form.Get("body") == "" || file != nil {
+				t.Errorf("body de %d bytes y archivo de %d bytes, se esperaba solo body", len(form.Get("body")), len(file))
+			}
+		})
+	}
+}
+
+func TestCheckCaptchaSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		img       []byte
+		wantErr   bool
+		wantSmall bool
+	}{
+		{name: "tamaño normal", img: pngImage(t, 120, 40)},
+		{name: "tamaño mínimo exacto", img: pngImage(t, 40, 15)},
+		{name: "muy angosta", img: pngImage(t, 10, 40), wantErr: true, wantSmall: true},
+		{name: "muy baja", img: pngImage(t, 120, 1), wantErr: true, wantSmall: true},
+		{name: "no es PNG", img: []byte("texto"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := getDefaultConfig()
+			config.APIKey = "clave"
+			s, err := NewScraper(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			fake, client := newFakeTwoCaptcha(t, func(url.Values) string { return `{"status":0,"request":"ERROR_ZERO_BALANCE"}` })
+			s.captcha = client
+
+			err = s.checkCaptchaSize(tt.img)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkCaptchaSize = %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, errCaptchaTooSmall); got != tt.wantSmall {
+				t.Errorf("errors.Is(errCaptchaTooSmall) = %v, se esperaba %v", got, tt.wantSmall)
+			}
+			// Una imagen rechazada no se envía a 2captcha
+			if tt.wantErr {
+				if _, _, err := s.solveCaptcha(tt.img); err == nil {
+					t.Error("solveCaptcha no devolvió error")
+				}
+				if n := len(fake.all()); n != 0 {
+					t.Errorf("se hicieron %d peticiones a 2captcha, se esperaban 0", n)
+				}
+			}
+		})
+	}
+}
+
+func TestCaptchaCache(t *testing.T) {
+	c := newCaptchaCache(2)
+	c.put("a", "abc12")
+	c.put("b", "def34")
+	c.put("a", "xyz99") // actualizar no cuenta como entrada nueva
+	c.put("c", "ghi56") // descarta la más antigua ("a")
+
+	tests := []struct {
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{"a", "", false},
+		{"b", "def34", true},
+		{"c", "ghi56", true},
+		{"d", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := c.get(tt.key); got != tt.want || ok != tt.wantOK {
+			t.Errorf("get(%q) = %q, %v; se esperaba %q, %v", tt.key, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	// Una caché nil (desactivada) no guarda nada
+	var disabled *captchaCache
+	disabled.put("a", "abc12")
+	if _, ok := disabled.get("a"); ok {
+		t.Error("la caché desactivada devolvió una respuesta")
+	}
+}
+
+// La misma imagen se resuelve una sola vez por ejecución
+func TestSolveCaptchaCache(t *testing.T) {
+	img := pngImage(t, 120, 40)
+	other := pngImage(t, 121, 40)
+
+	tests := []struct {
+		name        string
+		cacheSize   int
+		images      [][]byte
+		wantSubmits int
+		wantIDs     []string
+	}{
+		{"misma imagen dos veces", 10, [][]byte{img, img}, 1, []string{"1", ""}},
+		{"imágenes distintas", 10, [][]byte{img, other}, 2, []string{"1", "2"}},
+		{"sin caché", 0, [][]byte{img, img}, 2, []string{"1", "2"}},
+		// Con una sola entrada la imagen descartada se vuelve a enviar
+		{"caché llena", 1, [][]byte{img, other, img}, 3, []string{"1", "2", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := getDefaultConfig()
+			config.APIKey = "clave"
+			config.CaptchaCacheSize = tt.cacheSize
+			s, err := NewScraper(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			fake := withFakeCaptcha(t, s, "abc12")
+
+			var ids []string
+			for i, image := range tt.images {
+				answer, id, err := s.solveCaptcha(image)
+				if err != nil || answer != "abc12" {
+					t.Fatalf("imagen %d: %q, %v", i, answer, err)
+				}
+				ids = append(ids, id)
+			}
+			if n := len(fake.all()); n != tt.wantSubmits {
+				t.Errorf("%d envíos a 2captcha, se esperaban %d", n, tt.wantSubmits)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("IDs = %q, se esperaba %q", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestTwoCaptchaPoll(t *testing.T) {
+	tests := []struct {
+		name           string
+		response       string
+		wantAnswer     string
+		wantReady      bool
+		wantErr        bool
+		wantUnsolvable bool
+	}{
+		{name: "resuelto", response: `{"status":1,"request":"abc12"}`, wantAnswer: "abc12", wantReady: true},
+		{name: "pendiente", response: `{"status":0,"request":"CAPCHA_NOT_READY"}`},
+		{name: "sin solución", response: `{"status":0,"request":"ERROR_CAPTCHA_UNSOLVABLE"}`, wantErr: true, wantUnsolvable: true},
+		{name: "otro error", response: `{"status":0,"request":"ERROR_WRONG_CAPTCHA_ID"}`, wantErr: true},
+		{name: "respuesta inválida", response: `<html>`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeTwoCaptcha(t, func(url.Values) string { return tt.response })
+
+			answer, ready, err := client.Poll("42")
+			if answer != tt.wantAnswer || ready != tt.wantReady || (err != nil) != tt.wantErr {
+				t.Errorf("Poll = %q, %v, %v; se esperaba %q, %v, error: %v",
+					answer, ready, err, tt.wantAnswer, tt.wantReady, tt.wantErr)
+			}
+			if got := isCaptchaUnsolvable(err); got != tt.wantUnsolvable {
+				t.Errorf("isCaptchaUnsolvable = %v, se esperaba %v", got, tt.wantUnsolvable)
+			}
+			form := fake.last()
+			if form.Get("action") != "get" || form.Get("id") != "42" || form.Get("key") != "clave" {
+				t.Errorf("consulta a res.php = %v", form)
+			}
+		})
+	}
+}
+
+func TestTwoCaptchaBalance(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     float64
+		wantErr  bool
+	}{
+		{"con saldo", `{"status":1,"request":"3.7512"}`, 3.7512, false},
+		{"clave inválida", `{"status":0,"request":"ERROR_WRONG_USER_KEY"}`, 0, true},
+		{"saldo inválido", `{"status":1,"request":"mucho"}`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeTwoCaptcha(t, func(url.Values) string { return tt.response })
+
+			balance, err := client.Balance()
+			if balance != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("Balance = %v, %v; se esperaba %v, error: %v", balance, err, tt.want, tt.wantErr)
+			}
+			if action := fake.last().Get("action"); action != "getbalance" {
+				t.Errorf("action = %q, se esperaba getbalance", action)
+			}
+		})
+	}
+}
+
+func TestTwoCaptchaReport(t *testing.T) {
+	tests := []struct {
+		name       string
+		good       bool
+		response   string
+		wantAction string
+		wantErr    bool
+	}{
+		{"correcto", true, `{"status":1,"request":"OK_REPORT_RECORDED"}`, "reportgood", false},
+		{"incorrecto", false, `{"status":1,"request":"OK_REPORT_RECORDED"}`, "reportbad", false},
+		{"rechazado", false, `{"status":0,"request":"ERROR_DUPLICATE_REPORT"}`, "reportbad", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeTwoCaptcha(t, func(url.Values) string { return tt.response })
+
+			err := client.Report("42", tt.good)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Report = %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			form := fake.last()
+			if form.Get("action") != tt.wantAction || form.Get("id") != "42" {
+				t.Errorf("reporte = %v, se esperaba action=%s id=42", form, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestNewCaptchaHTTPClient(t *testing.T) {
+	defaults := http.DefaultTransport.(*http.Transport)
+	tests := []struct {
+		name        string
+		maxIdle     int
+		wantIdle    int
+		wantPerHost int
+	}{
+		{"sin límite usa el transporte por defecto", 0, defaults.MaxIdleConns, defaults.MaxIdleConnsPerHost},
+		{"límite de conexiones inactivas", 8, 8, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newCaptchaHTTPClient(tt.maxIdle, captchaHTTPTimeout)
+			transport, ok := client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("transporte %T, se esperaba *http.Transport", client.Transport)
+			}
+			if transport == defaults {
+				t.Error("el cliente no debe modificar http.DefaultTransport")
+			}
+			if transport.MaxIdleConns != tt.wantIdle || transport.MaxIdleConnsPerHost != tt.wantPerHost {
+				t.Errorf("MaxIdleConns %d, MaxIdleConnsPerHost %d; se esperaban %d y %d",
+					transport.MaxIdleConns, transport.MaxIdleConnsPerHost, tt.wantIdle, tt.wantPerHost)
+			}
+			if client.Timeout != captchaHTTPTimeout {
+				t.Errorf("Timeout = %v, se esperaba %v", client.Timeout, captchaHTTPTimeout)
+			}
+		})
+	}
+}
+
+// Muchas consultas simultáneas reutilizan las conexiones inactivas en vez de
+// abrir una por consulta
+func TestTwoCaptchaPollReusesConnections(t *testing.T) {
+	const (
+		workers = 8
+		rounds  = 5
+	)
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":0,"request":"CAPCHA_NOT_READY"}`))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	client := NewTwoCaptchaClient("clave")
+	client.ResultURL = srv.URL + "/res.php"
+	client.HTTPClient = newCaptchaHTTPClient(workers, captchaHTTPTimeout)
+
+	for round := 0; round < rounds; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, _, err := client.Poll("42"); err != nil {
+					t.Errorf("Poll: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	if got := conns.Load(); got > 2*workers {
+		t.Errorf("%d conexiones para %d consultas, se esperaban como máximo %d", got, workers*rounds, 2*workers)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Columna de la salida: nombre (el del JSON de Result), encabezado en Excel y
+// valor. Cell, si está, reemplaza a Value en Excel. Con Text los valores de
+// texto se escriben con formato de texto (@), para que Excel no los
+// convierta en número o fecha al editarlos (ceros a la izquierda, 2024-01-31)
+type ColumnSpec struct {
+	Name   string
+	Header string
+	Value  func(Result) interface{}
+	Cell   func(Result) interface{}
+	Text   bool
+}
+
+func (c ColumnSpec) excelValue(r Result) interface{} {
+	if c.Cell != nil {
+		return c.Cell(r)
+	}
+	return c.Value(r)
+}
+
+// El valor va con formato de texto: columna de texto y valor string (un
+// tiempo numérico sigue siendo número)
+func (c ColumnSpec) textCell(value interface{}) bool {
+	_, isString := value.(string)
+	return c.Text && isString
+}
+
+// Columnas de la salida completa, en el orden de la hoja de resultados
+var defaultColumns = []ColumnSpec{
+	{Name: "cedula", Header: "Cedula", Value: func(r Result) interface{} { return r.Cedula }, Text: true},
+	{Name: "originalCedula", Header: "Cedula Original", Value: func(r Result) interface{} { return r.OriginalCedula }, Text: true},
+	{Name: "primerApellido", Header: "Primer Apellido", Value: func(r Result) interface{} { return r.PrimerApellido }},
+	{Name: "segundoApellido", Header: "Segundo Apellido", Value: func(r Result) interface{} { return r.SegundoApellido }},
+	{Name: "primerNombre", Header: "Primer Nombre", Value: func(r Result) interface{} { return r.PrimerNombre }},
+	{Name: "segundoNombre", Header: "Segundo Nombre", Value: func(r Result) interface{} { return r.SegundoNombre }},
+	{Name: "estado", Header: "Estado", Value: func(r Result) interface{} { return r.Estado }},
+	{Name: "fechaInscripcion", Header: "Fecha Inscripcion", Value: func(r Result) interface{} { return r.FechaInscripcion }, Text: true},
+	{Name: "attempts", Header: "Intentos", Value: func(r Result) interface{} { return r.Attempts }},
+	{Name: "error", Header: "Error", Value: func(r Result) interface{} { return r.Error }},
+	{Name: "errorCode", Header: "Codigo Error", Value: func(r Result) interface{} { return r.ErrorCode }},
+	{Name: "processingTime", Header: "Tiempo", Value: func(r Result) interface{} { return r.ProcessingTime },
+		Cell: func(r Result) interface{} { return processingTimeCell(r.ProcessingTime) }, Text: true},
+	{Name: "source", Header: "Origen", Value: func(r Result) interface{} { return r.Source }, Text: true},
+	{Name: "httpStatus", Header: "Estado HTTP", Value: func(r Result) interface{} { return r.HTTPStatus },
+		Cell: func(r Result) interface{} { return httpStatusCell(r.HTTPStatus) }},
+}
+
+// Columnas pedidas por nombre (sin distinguir mayúsculas), en ese orden.
+// Sin nombres se devuelven todas
+func selectColumns(names []string) ([]ColumnSpec, error) {
+	if len(names) == 0 {
+		return defaultColumns, nil
+	}
+
+	byName := make(map[string]ColumnSpec, len(defaultColumns))
+	valid := make([]string, len(defaultColumns))
+	for i, col := range defaultColumns {
+		byName[strings.ToLower(col.Name)] = col
+		valid[i] = col.Name
+	}
+
+	columns := make([]ColumnSpec, 0, len(names))
+	for _, name := range names {
+		col, ok := byName[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("columna desconocida %q; columnas válidas: %s", name, strings.Join(valid, ", "))
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+// Columna copiada tal cual de la entrada (Result.Meta); el encabezado es el
+// nombre pedido
+func metaColumn(name string) ColumnSpec {
+	return ColumnSpec{Name: name, Header: name, Value: func(r Result) interface{} { return r.Meta[name] }, Text: true}
+}
+
+// Columnas de la salida seguidas de las columnas copiadas de la entrada
+func withMetaColumns(columns []ColumnSpec, names []string) []ColumnSpec {
+	if len(names) == 0 {
+		return columns
+	}
+	out := make([]ColumnSpec, 0, len(columns)+len(names))
+	out = append(out, columns...)
+	for _, name := range names {
+		out = append(out, metaColumn(name))
+	}
+	return out
+}
+
+func columnHeaders(columns []ColumnSpec) []string {
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = col.Header
+	}
+	return headers
+}
+
+// Objeto JSON con solo las columnas indicadas, en su orden
+func columnsJSON(r Result, columns []ColumnSpec) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, col := range columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(col.Name)
+		value, err := json.Marshal(col.Value(r))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
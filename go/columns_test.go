@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func columnNames(columns []ColumnSpec) []string {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+func TestSelectColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "todas", names: nil, want: columnNames(defaultColumns)},
+		{name: "en el orden pedido", names: []string{"estado", "cedula"}, want: []string{"estado", "cedula"}},
+		{name: "sin distinguir mayúsculas", names: []string{" CEDULA ", "primerapellido"}, want: []string{"cedula", "primerApellido"}},
+		{name: "columna desconocida", names: []string{"cedula", "telefono"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectColumns(tt.names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), "fechaInscripcion") {
+					t.Errorf("el error no lista las columnas válidas: %v", err)
+				}
+				return
+			}
+			if names := columnNames(got); !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("columnas = %v, se esperaba %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnsJSON(t *testing.T) {
+	result := Result{Cedula: "0012345", Estado: "REGISTRO ACTIVO", PrimerNombre: "JUAN", Attempts: 2, Meta: map[string]string{"Sede": "Cali"}}
+	tests := []struct {
+		name    string
+		columns []string
+		meta    []string
+		want    string
+	}{
+		{"dos columnas", []string{"cedula", "estado"}, nil, `{"cedula":"0012345","estado":"REGISTRO ACTIVO"}`},
+		{"número", []string{"attempts"}, nil, `{"attempts":2}`},
+		{"con columnas de la entrada", []string{"cedula"}, []string{"Sede"}, `{"cedula":"0012345","Sede":"Cali"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, err := selectColumns(tt.columns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := columnsJSON(result, withMetaColumns(columns, tt.meta))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("columnsJSON = %s, se esperaba %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// Con -columns cedula,estado las salidas solo tienen esas dos columnas
+func TestSelectedColumnsOutput(t *testing.T) {
+	results := []Result{
+		{Cedula: "1", Estado: "REGISTRO ACTIVO", PrimerNombre: "JUAN"},
+		{Cedula: "2", Estado: "REGISTRO CANCELADO", PrimerNombre: "ANA"},
+	}
+	columns, err := selectColumns([]string{"cedula", "estado"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	t.Run("excel", func(t *testing.T) {
+		path := filepath.Join(dir, "resultados.xlsx")
+		if err := writeResultsToExcel(path, results, OutputOptions{Columns: columns}); err != nil {
+			t.Fatal(err)
+		}
+		f, err := excelize.OpenFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		rows, err := f.GetRows("Results")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := [][]string{{"Cedula", "Estado"}, {"1", "REGISTRO ACTIVO"}, {"2", "REGISTRO CANCELADO"}}
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("filas = %v, se esperaba %v", rows, want)
+		}
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		path := filepath.Join(dir, "resultados.jsonl")
+		if err := writeResultsToJSONL(path, results, columns); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) != len(results) {
+			t.Fatalf("%d líneas, se esperaban %d", len(lines), len(results))
+		}
+		for i, line := range lines {
+			var got map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &got); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]interface{}{"cedula": results[i].Cedula, "estado": results[i].Estado}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("línea %d = %v, se esperaba %v", i+1, got, want)
+			}
+		}
+	})
+}
+
+func TestTextCell(t *testing.T) {
+	text := ColumnSpec{Name: "cedula", Text: true}
+	plain := ColumnSpec{Name: "estado"}
+	tests := []struct {
+		name   string
+		column ColumnSpec
+		value  interface{}
+		want   bool
+	}{
+		{"columna de texto", text, "0012345", true},
+		{"columna de texto vacía", text, "", true},
+		// Un tiempo numérico en una columna de texto sigue siendo número
+		{"número en columna de texto", text, 12.5, false},
+		{"columna normal", plain, "REGISTRO ACTIVO", false},
+	}
+	for _, tt := range tests {
+		if got := tt.column.textCell(tt.value); got != tt.want {
+			t.Errorf("%s: textCell(%v) = %v, se esperaba %v", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+// Las columnas de texto se guardan con formato "@" en los dos caminos de
+// escritura, para que Excel no quite ceros ni convierta fechas
+func TestExcelTextFormat(t *testing.T) {
+	result := Result{
+		Cedula:           "0012345",
+		Estado:           "REGISTRO ACTIVO",
+		FechaInscripcion: "2024-01-31",
+		Attempts:         2,
+		Meta:             map[string]string{"Codigo": "007"},
+	}
+	tests := []struct {
+		name string
+		rows int
+	}{
+		{"SetCellValue", 1},
+		{"StreamWriter", excelStreamThreshold + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make([]Result, tt.rows)
+			for i := range results {
+				results[i] = result
+			}
+			path := filepath.Join(t.TempDir(), "resultados.xlsx")
+			if err := writeResultsToExcel(path, results, OutputOptions{Passthrough: []string{"Codigo"}}); err != nil {
+				t.Fatalf("writeResultsToExcel: %v", err)
+			}
+			f, err := excelize.OpenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			headers, err := f.GetRows("Results")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, check := range []struct {
+				header   string
+				want     string
+				wantText bool
+			}{
+				{"Cedula", "0012345", true},
+				{"Fecha Inscripcion", "2024-01-31", true},
+				{"Codigo", "007", true},
+				{"Estado", "REGISTRO ACTIVO", false},
+				{"Intentos", "2", false},
+			} {
+				col := -1
+				for i, header := range headers[0] {
+					if header == check.header {
+						col = i
+					}
+				}
+				if col < 0 {
+					t.Fatalf("falta la columna %s en %v", check.header, headers[0])
+				}
+				cell, _ := excelize.CoordinatesToCellName(col+1, 2)
+				value, _ := f.GetCellValue("Results", cell)
+				if value != check.want {
+					t.Errorf("%s = %q, se esperaba %q", check.header, value, check.want)
+				}
+				styleID, err := f.GetCellStyle("Results", cell)
+				if err != nil {
+					t.Fatal(err)
+				}
+				isText := false
+				if styleID != 0 {
+					style, err := f.GetStyle(styleID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					isText = style.NumFmt == 49
+				}
+				if isText != check.wantText {
+					t.Errorf("%s con formato de texto: %v, se esperaba %v", check.header, isText, check.wantText)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Diferencia en un campo de una cédula entre dos ejecuciones
+type Diff struct {
+	Cedula string
+	Field  string
+	Before string
+	After  string
+}
+
+// Campos comparados entre ejecuciones (datos de la DIAN): nombre en el
+// reporte y nombre en resultFields. Tanto Equal como diffResults usan esta
+// lista, así que un par igual nunca aparece en el reporte
+var diffFields = []struct {
+	name string
+	key  string
+}{
+	{"Estado", "estado"},
+	{"Primer Apellido", "primerapellido"},
+	{"Segundo Apellido", "segundoapellido"},
+	{"Primer Nombre", "primernombre"},
+	{"Segundo Nombre", "segundonombre"},
+	{"Fecha Inscripcion", "fechainscripcion"},
+}
+
+// Comparación canónica de resultados para -diff y verificaciones: solo se
+// comparan los campos de diffFields, nunca campos volátiles como
+// ProcessingTime o Attempts, y sin distinguir mayúsculas ni espacios. Ignore
+// (nombres de resultFields en minúsculas) excluye campos adicionales
+type ResultComparer struct {
+	Ignore map[string]bool
+}
+
+func newResultComparer(ignore []string) ResultComparer {
+	c := ResultComparer{Ignore: make(map[string]bool, len(ignore))}
+	for _, name := range ignore {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			c.Ignore[name] = true
+		}
+	}
+	return c
+}
+
+// Igualdad de un campo por nombre, tras normalizar ambos valores
+func (c ResultComparer) fieldEqual(name string, a, b Result) bool {
+	get, ok := resultFields[name]
+	if !ok || c.Ignore[name] {
+		return true
+	}
+	return normalizeCompared(get(a)) == normalizeCompared(get(b))
+}
+
+func (c ResultComparer) Equal(a, b Result) bool {
+	for _, field := range diffFields {
+		if !c.fieldEqual(field.key, a, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// " pérez  gómez" y "PÉREZ GÓMEZ" se consideran iguales
+func normalizeCompared(value string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(value), " "))
+}
+
+// Comparar dos conjuntos de resultados por cédula. Las cédulas presentes en
+// uno solo de los conjuntos se reportan con el campo "Cedula"
+func diffResults(a, b []Result, cmp ResultComparer) []Diff {
+	byCedula := make(map[string]Result, len(b))
+	for _, r := range b {
+		byCedula[r.Cedula] = r
+	}
+
+	var diffs []Diff
+	seen := make(map[string]bool, len(a))
+	for _, before := range a {
+		seen[before.Cedula] = true
+		after, ok := byCedula[before.Cedula]
+		if !ok {
+			diffs = append(diffs, Diff{Cedula: before.Cedula, Field: "Cedula", Before: before.Cedula})
+			continue
+		}
+		for _, field := range diffFields {
+			if !cmp.fieldEqual(field.key, before, after) {
+				get := resultFields[field.key]
+				diffs = append(diffs, Diff{Cedula: before.Cedula, Field: field.name, Before: get(before), After: get(after)})
+			}
+		}
+	}
+
+	for _, after := range b {
+		if !seen[after.Cedula] {
+			diffs = append(diffs, Diff{Cedula: after.Cedula, Field: "Cedula", After: after.Cedula})
+		}
+	}
+
+	return diffs
+}
+
+// Leer un archivo generado por writeResultsToExcel, ubicando las columnas por encabezado
+func readResultsFromExcel(filename string) ([]Result, error) {
+	f, err := openWithRetry(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo archivo Excel: %v", err)
+	}
+	defer f.Close()
+
+	// La hoja de resumen, si existe, va antes que la de resultados
+	sheet := f.GetSheetName(0)
+	if idx, _ := f.GetSheetIndex("Results"); idx >= 0 {
+		sheet = "Results"
+	}
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo filas: %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int, len(rows[0]))
+	for i, header := range rows[0] {
+		columns[header] = i
+	}
+	if _, ok := columns["Cedula"]; !ok {
+		return nil, fmt.Errorf("el archivo %s no tiene columna Cedula", filename)
+	}
+
+	cell := func(row []string, header string) string {
+		if i, ok := columns[header]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	results := make([]Result, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		attempts, _ := strconv.Atoi(cell(row, "Intentos"))
+		httpStatus, _ := strconv.Atoi(cell(row, "Estado HTTP"))
+		results = append(results, Result{
+			Cedula:           strings.TrimSpace(cell(row, "Cedula")),
+			OriginalCedula:   cell(row, "Cedula Original"),
+			PrimerApellido:   cell(row, "Primer Apellido"),
+			SegundoApellido:  cell(row, "Segundo Apellido"),
+			PrimerNombre:     cell(row, "Primer Nombre"),
+			SegundoNombre:    cell(row, "Segundo Nombre"),
+			Estado:           cell(row, "Estado"),
+			FechaInscripcion: cell(row, "Fecha Inscripcion"),
+			Attempts:         attempts,
+			Error:            cell(row, "Error"),
+			ErrorCode:        cell(row, "Codigo Error"),
+			HTTPStatus:       httpStatus,
+			ProcessingTime:   cell(row, "Tiempo"),
+			Source:           cell(row, "Origen"),
+		})
+	}
+
+	return results, nil
+}
+
+func writeDiffsToExcel(filename string, diffs []Diff) error {
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := "Diferencias"
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return fmt.Errorf("error creando hoja %s: %v", sheet, err)
+	}
+
+	headers := []string{"Cedula", "Campo", "Antes", "Despues"}
+	for i, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheet, cell, header)
+	}
+
+	for i, diff := range diffs {
+		row := i + 2
+		f.SetCellValue(sheet, fmt.Sprintf("A%d", row), diff.Cedula)
+		f.SetCellValue(sheet, fmt.Sprintf("B%d", row), diff.Field)
+		f.SetCellValue(sheet, fmt.Sprintf("C%d", row), diff.Before)
+		f.SetCellValue(sheet, fmt.Sprintf("D%d", row), diff.After)
+	}
+
+	return saveWithRetry(f, filename)
+}
+
+// Subcomando -diff: comparar dos archivos de resultados y escribir el reporte
+func runDiff(fileA, fileB, reportFile string, cmp ResultComparer) error {
+	a, err := readResultsFromExcel(fileA)
+	if err != nil {
+		return err
+	}
+	b, err := readResultsFromExcel(fileB)
+	if err != nil {
+		return err
+	}
+
+	diffs := diffResults(a, b, cmp)
+	log.Printf("Se encontraron %d diferencias entre %s y %s", len(diffs), fileA, fileB)
+
+	if err := writeDiffsToExcel(reportFile, diffs); err != nil {
+		return fmt.Errorf("error guardando reporte de diferencias: %v", err)
+	}
+	log.Printf("Reporte de diferencias guardado en: %s", reportFile)
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestDiffResults(t *testing.T) {
+	activo := Result{Cedula: "1", PrimerApellido: "PEREZ", PrimerNombre: "JUAN", Estado: "REGISTRO ACTIVO"}
+	cancelado := activo
+	cancelado.Estado = "REGISTRO CANCELADO"
+	volatil := activo
+	volatil.Attempts = 3
+	volatil.ProcessingTime = "9s"
+
+	tests := []struct {
+		name string
+		a, b []Result
+		want []Diff
+	}{
+		{
+			name: "iguales",
+			a:    []Result{activo},
+			b:    []Result{activo},
+		},
+		{
+			name: "campos volátiles no cuentan",
+			a:    []Result{activo},
+			b:    []Result{volatil},
+		},
+		{
+			name: "cambio de estado",
+			a:    []Result{activo},
+			b:    []Result{cancelado},
+			want: []Diff{{Cedula: "1", Field: "Estado", Before: "REGISTRO ACTIVO", After: "REGISTRO CANCELADO"}},
+		},
+		{
+			name: "cédula solo en el primero",
+			a:    []Result{activo, {Cedula: "2"}},
+			b:    []Result{activo},
+			want: []Diff{{Cedula: "2", Field: "Cedula", Before: "2"}},
+		},
+		{
+			name: "cédula solo en el segundo",
+			a:    []Result{activo},
+			b:    []Result{activo, {Cedula: "3"}},
+			want: []Diff{{Cedula: "3", Field: "Cedula", After: "3"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffResults(tt.a, tt.b, newResultComparer(nil))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffResults = %+v, se esperaba %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultComparerEqual(t *testing.T) {
+	base := Result{Cedula: "1", PrimerApellido: "PÉREZ", SegundoApellido: "GÓMEZ", PrimerNombre: "JUAN", Estado: "REGISTRO ACTIVO"}
+	with := func(change func(*Result)) Result {
+		r := base
+		change(&r)
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		b      Result
+		ignore []string
+		want   bool
+	}{
+		{"idénticos", base, nil, true},
+		{"mayúsculas y espacios", with(func(r *Result) { r.PrimerApellido = "  pérez "; r.Estado = "registro  activo" }), nil, true},
+		{"campos volátiles", with(func(r *Result) { r.Attempts = 4; r.ProcessingTime = "12s"; r.HTTPStatus = 200 }), nil, true},
+		{"cambio de estado", with(func(r *Result) { r.Estado = "REGISTRO CANCELADO" }), nil, false},
+		{"cambio de apellido", with(func(r *Result) { r.SegundoApellido = "GOMEZ" }), nil, false},
+		{"nombre vacío", with(func(r *Result) { r.PrimerNombre = "" }), nil, false},
+		{"campo ignorado", with(func(r *Result) { r.Estado = "REGISTRO CANCELADO" }), []string{" Estado "}, true},
+		{"ignorar otro campo no alcanza", with(func(r *Result) { r.Estado = "REGISTRO CANCELADO" }), []string{"primerNombre"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmp := newResultComparer(tt.ignore)
+			if got := cmp.Equal(base, tt.b); got != tt.want {
+				t.Errorf("Equal = %v, se esperaba %v", got, tt.want)
+			}
+			// Equal y diffResults coinciden
+			if diffs := diffResults([]Result{base}, []Result{tt.b}, cmp); (len(diffs) == 0) != tt.want {
+				t.Errorf("diffResults = %+v con Equal %v", diffs, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadResultsFromExcel(t *testing.T) {
+	results := []Result{
+		{Cedula: "1012345678", PrimerApellido: "PEREZ", PrimerNombre: "JUAN", Estado: "REGISTRO ACTIVO", Attempts: 1},
+		{Cedula: "79123456", Estado: "Error", Error: "Límite de captchas", ErrorCode: errCodeCaptchaLimit, Attempts: 3},
+	}
+	tests := []struct {
+		name    string
+		results []Result
+		summary bool
+	}{
+		{"sin resultados", nil, false},
+		{"con resultados", results, false},
+		// La hoja de resumen va primero; se leen los resultados igual
+		{"con hoja de resumen", results, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "resultados.xlsx")
+			if err := writeResultsToExcel(path, tt.results, OutputOptions{SummarySheet: tt.summary}); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readResultsFromExcel(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.results) {
+				t.Fatalf("se leyeron %d resultados, se esperaban %d", len(got), len(tt.results))
+			}
+			for i, want := range tt.results {
+				r := got[i]
+				if r.Cedula != want.Cedula || r.Estado != want.Estado || r.PrimerApellido != want.PrimerApellido ||
+					r.Attempts != want.Attempts || r.ErrorCode != want.ErrorCode {
+					t.Errorf("resultado %d = %+v, se esperaba %+v", i, r, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	dir := t.TempDir()
+	fileA := filepath.Join(dir, "a.xlsx")
+	fileB := filepath.Join(dir, "b.xlsx")
+	report := filepath.Join(dir, "diferencias.xlsx")
+
+	if err := writeResultsToExcel(fileA, []Result{{Cedula: "1", Estado: "REGISTRO ACTIVO"}}, OutputOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeResultsToExcel(fileB, []Result{{Cedula: "1", Estado: "REGISTRO CANCELADO"}}, OutputOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runDiff(fileA, fileB, report, newResultComparer(nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows("Diferencias")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Cedula", "Campo", "Antes", "Despues"},
+		{"1", "Estado", "REGISTRO ACTIVO", "REGISTRO CANCELADO"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("reporte = %v, se esperaba %v", rows, want)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/chromedp/cdproto"
+	"github.com/chromedp/chromedp"
+)
+
+// Códigos de los errores de navegación, según su causa probable
+const (
+	errCodeNetwork = "NETWORK_ERROR" // DNS, conexión o proxy
+	errCodeTLS     = "TLS_ERROR"     // certificado inválido o proxy que intercepta TLS
+	errCodeTimeout = "TIMEOUT"       // la página tardó demasiado
+	errCodeBrowser = "BROWSER_ERROR" // protocolo de Chrome o pestaña cerrada
+)
+
+// Fragmentos de los códigos net::ERR_* de Chrome que indican problemas de red
+var networkErrorMarkers = []string{
+	"net::err_name_not_resolved",
+	"net::err_connection",
+	"net::err_address_unreachable",
+	"net::err_internet_disconnected",
+	"net::err_network",
+	"net::err_proxy",
+	"net::err_tunnel",
+	"net::err_empty_response",
+}
+
+// Fragmentos de los códigos net::ERR_* de Chrome que indican un problema de
+// certificado o de TLS (certificado vencido, proxy que intercepta)
+var tlsErrorMarkers = []string{
+	"net::err_ssl",
+	"net::err_cert",
+	"net::err_bad_ssl",
+}
+
+// Qué hacer ante un error TLS con la DIAN
+const (
+	tlsActionRetry = "retry" // reintentar la cédula como cualquier error de red
+	tlsActionAbort = "abort" // detener la ejecución: casi siempre es un proxy mal configurado
+)
+
+// Clasificar un error de navegación para distinguir red/proxy, lentitud del
+// sitio y fallos de Chrome. Devuelve el código y un mensaje para Result.Error
+func classifyNavError(err error) (string, string) {
+	msg := strings.ToLower(err.Error())
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		strings.Contains(msg, "net::err_timed_out"),
+		strings.Contains(msg, "no cargó a tiempo"):
+		return errCodeTimeout, "Tiempo de espera agotado al navegar"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return errCodeTimeout, "Tiempo de espera agotado al navegar"
+		}
+		return errCodeNetwork, "Error de red al navegar"
+	}
+	for _, marker := range tlsErrorMarkers {
+		if strings.Contains(msg, marker) {
+			return errCodeTLS, "Error de certificado TLS al navegar"
+		}
+	}
+	for _, marker := range networkErrorMarkers {
+		if strings.Contains(msg, marker) {
+			return errCodeNetwork, "Error de red al navegar"
+		}
+	}
+
+	var protoErr *cdproto.Error
+	if errors.As(err, &protoErr) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, chromedp.ErrInvalidContext) || errors.Is(err, chromedp.ErrChannelClosed) ||
+		strings.Contains(msg, "websocket") || strings.Contains(msg, "target closed") {
+		return errCodeBrowser, "Error del navegador"
+	}
+	return "", "Error al navegar"
+}
+
+// Chrome mostró su página de advertencia de seguridad ("la conexión no es
+// privada") en lugar de la página de la DIAN
+func pageTLSInterstitial(ctx context.Context) bool {
+	const expr = `(() => {
+		if (document.querySelector('body.ssl, #proceed-link')) return true;
+		const text = document.body ? document.body.innerText : '';
+		return /NET::ERR_CERT|ERR_SSL|ERR_BAD_SSL/.test(text);
+	})()`
+	var interstitial bool
+	if err := chromedp.Run(ctx, chromedp.Evaluate(expr, &interstitial)); err != nil {
+		return false
+	}
+	return interstitial
+}
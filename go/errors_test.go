@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/chromedp/cdproto"
+	"github.com/chromedp/chromedp"
+)
+
+func TestClassifyNavError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"plazo vencido", context.DeadlineExceeded, errCodeTimeout},
+		{"plazo vencido envuelto", fmt.Errorf("navegando: %w", context.DeadlineExceeded), errCodeTimeout},
+		{"timeout de Chrome", errors.New("page load error net::ERR_TIMED_OUT"), errCodeTimeout},
+		{"página lenta", errors.New("la página no cargó a tiempo"), errCodeTimeout},
+		{"timeout de red", &net.DNSError{Err: "timeout", Name: "muisca.dian.gov.co", IsTimeout: true}, errCodeTimeout},
+		{"conexión rechazada", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, errCodeNetwork},
+		{"DNS de Chrome", errors.New("page load error net::ERR_NAME_NOT_RESOLVED"), errCodeNetwork},
+		{"proxy", errors.New("page load error net::ERR_PROXY_CONNECTION_FAILED"), errCodeNetwork},
+		{"certificado", errors.New("page load error net::ERR_CERT_AUTHORITY_INVALID"), errCodeTLS},
+		{"SSL", errors.New("page load error net::ERR_SSL_PROTOCOL_ERROR"), errCodeTLS},
+		{"protocolo de Chrome", &cdproto.Error{Code: -32000, Message: "Cannot navigate to invalid URL"}, errCodeBrowser},
+		{"cancelado", context.Canceled, errCodeBrowser},
+		{"contexto inválido", chromedp.ErrInvalidContext, errCodeBrowser},
+		{"pestaña cerrada", errors.New("target closed"), errCodeBrowser},
+		{"desconocido", errors.New("algo raro"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, message := classifyNavError(tt.err)
+			if code != tt.want {
+				t.Errorf("classifyNavError(%v) = %q, se esperaba %q", tt.err, code, tt.want)
+			}
+			if message == "" {
+				t.Error("mensaje vacío")
+			}
+		})
+	}
+}
+
+// Advertencia de certificado de Chrome frente a páginas normales
+func TestPageTLSInterstitial(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFixtureServer(t)
+
+	tests := []struct {
+		page string
+		want bool
+	}{
+		{"tls_interstitial.html", true},
+		{"dian.html", false},
+		{"error.html", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			tabCtx, cancel := chromedp.NewContext(ctx)
+			defer cancel()
+			if err := chromedp.Run(tabCtx, chromedp.Navigate(srv.URL+"/"+tt.page)); err != nil {
+				t.Fatal(err)
+			}
+			if got := pageTLSInterstitial(tabCtx); got != tt.want {
+				t.Errorf("pageTLSInterstitial = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Expresiones por defecto para leer los campos del texto visible de la
+// página ("Primer Apellido: PEREZ"). El primer grupo es el valor, que no
+// puede empezar con ":" para que una etiqueta vacía no dé ":" como valor
+var defaultFallbackPatterns = map[string]string{
+	"primerApellido":  `(?im)^\s*Primer\s+Apellido\s*:?[ \t]*([^:\s].*?)\s*$`,
+	"segundoApellido": `(?im)^\s*Segundo\s+Apellido\s*:?[ \t]*([^:\s].*?)\s*$`,
+	"primerNombre":    `(?im)^\s*Primer\s+Nombre\s*:?[ \t]*([^:\s].*?)\s*$`,
+	"segundoNombre":   `(?im)^\s*Otros\s+Nombres\s*:?[ \t]*([^:\s].*?)\s*$`,
+	"estado":          `(?im)^\s*Estado(?:\s+del\s+RUT)?\s*:?[ \t]*([^:\s].*?)\s*$`,
+}
+
+// Compilar las expresiones de respaldo; las claves son nombres de resultFields
+func compileFallbackPatterns(patterns map[string]string) (map[string]*regexp.Regexp, error) {
+	compiled := make(map[string]*regexp.Regexp, len(patterns))
+	for field, pattern := range patterns {
+		if _, ok := resultFields[strings.ToLower(field)]; !ok {
+			return nil, fmt.Errorf("campo desconocido en expresión de respaldo: %s", field)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("expresión de respaldo inválida para %s: %v", field, err)
+		}
+		if re.NumSubexp() < 1 {
+			return nil, fmt.Errorf("la expresión de respaldo para %s no tiene grupo de captura", field)
+		}
+		compiled[strings.ToLower(field)] = re
+	}
+	return compiled, nil
+}
+
+// Aplicar las expresiones al texto de la página. Devuelve los campos
+// encontrados (claves en minúsculas, como resultFields)
+func extractFromText(text string, patterns map[string]*regexp.Regexp) map[string]string {
+	values := make(map[string]string)
+	for field, re := range patterns {
+		if m := re.FindStringSubmatch(text); m != nil {
+			if value := strings.TrimSpace(m[1]); value != "" {
+				values[field] = value
+			}
+		}
+	}
+	return values
+}
+
+// Extracción de respaldo cuando fallan los selectores por id (cambio en el
+// HTML de la DIAN): se lee el texto visible y se buscan las etiquetas. Solo
+// se acepta si se encontró al menos el estado
+func fallbackExtract(ctx context.Context, patterns map[string]*regexp.Regexp) (map[string]string, bool) {
+	var text string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(`document.body ? document.body.innerText : ''`, &text)); err != nil {
+		return nil, false
+	}
+	values := extractFromText(text, patterns)
+	if values["estado"] == "" {
+		return nil, false
+	}
+	return values, true
+}
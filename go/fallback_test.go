@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/chromedp/chromedp"
+)
+
+func TestCompileFallbackPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns map[string]string
+		wantErr  bool
+	}{
+		{"expresiones por defecto", defaultFallbackPatterns, false},
+		{"campo sin distinguir mayúsculas", map[string]string{"Estado": `Estado: (.+)`}, false},
+		{"campo desconocido", map[string]string{"telefono": `Tel: (.+)`}, true},
+		{"expresión inválida", map[string]string{"estado": `Estado: (.+`}, true},
+		{"sin grupo de captura", map[string]string{"estado": `Estado: .+`}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compiled, err := compileFallbackPatterns(tt.patterns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(compiled) != len(tt.patterns) {
+				t.Errorf("%d expresiones compiladas, se esperaban %d", len(compiled), len(tt.patterns))
+			}
+		})
+	}
+}
+
+func TestExtractFromText(t *testing.T) {
+	defaults, err := compileFallbackPatterns(defaultFallbackPatterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom, err := compileFallbackPatterns(map[string]string{"estado": `(?i)situaci[oó]n\s*=\s*(\w+(?: \w+)*)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		text     string
+		patterns map[string]*regexp.Regexp
+		want     map[string]string
+	}{
+		{
+			name:     "campos etiquetados",
+			text:     "Primer Apellido: LOPEZ\nSegundo Apellido: DIAZ\nPrimer Nombre: PEDRO\nEstado del RUT: REGISTRO ACTIVO\n",
+			patterns: defaults,
+			want:     map[string]string{"primerapellido": "LOPEZ", "segundoapellido": "DIAZ", "primernombre": "PEDRO", "estado": "REGISTRO ACTIVO"},
+		},
+		{
+			name:     "etiqueta sin valor",
+			text:     "Primer Nombre: PEDRO\nOtros Nombres:\nEstado: REGISTRO ACTIVO",
+			patterns: defaults,
+			want:     map[string]string{"primernombre": "PEDRO", "estado": "REGISTRO ACTIVO"},
+		},
+		{
+			name:     "etiquetas con tabulador y espacios",
+			text:     "  Primer   Apellido\tGOMEZ  \nEstado : SUSPENDIDO",
+			patterns: defaults,
+			want:     map[string]string{"primerapellido": "GOMEZ", "estado": "SUSPENDIDO"},
+		},
+		{
+			name:     "sin etiquetas",
+			text:     "El NIT no está inscrito en el RUT",
+			patterns: defaults,
+			want:     map[string]string{},
+		},
+		{
+			name:     "expresión configurada",
+			text:     "situación = REGISTRO CANCELADO",
+			patterns: custom,
+			want:     map[string]string{"estado": "REGISTRO CANCELADO"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractFromText(tt.text, tt.patterns); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractFromText = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Página sin los ids de la DIAN pero con los campos etiquetados
+func TestFallbackExtract(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFixtureServer(t)
+	patterns, err := compileFallbackPatterns(defaultFallbackPatterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		page   string
+		wantOK bool
+		want   map[string]string
+	}{
+		{"texto.html", true, map[string]string{"primerapellido": "LOPEZ", "segundoapellido": "DIAZ", "primernombre": "PEDRO", "estado": "REGISTRO ACTIVO"}},
+		{"error.html", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			tabCtx, cancel := chromedp.NewContext(ctx)
+			defer cancel()
+			if err := chromedp.Run(tabCtx, chromedp.Navigate(srv.URL+"/"+tt.page)); err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok := fallbackExtract(tabCtx, patterns)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fallbackExtract = %v, %v; se esperaba %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
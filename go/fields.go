@@ -0,0 +1,34 @@
+package main
+
+import "strings"
+
+// Campos de Result accesibles por nombre (el mismo que usa el JSON)
+var resultFields = map[string]func(Result) string{
+	"cedula":           func(r Result) string { return r.Cedula },
+	"primerapellido":   func(r Result) string { return r.PrimerApellido },
+	"segundoapellido":  func(r Result) string { return r.SegundoApellido },
+	"primernombre":     func(r Result) string { return r.PrimerNombre },
+	"segundonombre":    func(r Result) string { return r.SegundoNombre },
+	"estado":           func(r Result) string { return r.Estado },
+	"fechainscripcion": func(r Result) string { return r.FechaInscripcion },
+}
+
+// Valor de un campo por nombre, sin distinguir mayúsculas
+func resultField(r Result, name string) (string, bool) {
+	get, ok := resultFields[strings.ToLower(name)]
+	if !ok {
+		return "", false
+	}
+	return get(r), true
+}
+
+// Campos requeridos que están vacíos (o no existen) en el resultado
+func missingFields(r Result, fields []string) []string {
+	var missing []string
+	for _, name := range fields {
+		if value, ok := resultField(r, name); !ok || strings.TrimSpace(value) == "" {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMissingFields(t *testing.T) {
+	completo := Result{PrimerApellido: "PEREZ", PrimerNombre: "JUAN", Estado: "REGISTRO ACTIVO"}
+	tests := []struct {
+		name   string
+		result Result
+		fields []string
+		want   []string
+	}{
+		{"todos presentes", completo, []string{"primerNombre", "primerApellido", "estado"}, nil},
+		{"sin campos requeridos", Result{}, nil, nil},
+		{"estado vacío", Result{PrimerNombre: "JUAN"}, []string{"primerNombre", "estado"}, []string{"estado"}},
+		{"solo espacios", Result{PrimerNombre: "  ", Estado: "\t"}, []string{"primerNombre", "estado"}, []string{"primerNombre", "estado"}},
+		{"sin distinguir mayúsculas", completo, []string{"PRIMERNOMBRE", "Estado"}, nil},
+		{"campo desconocido", completo, []string{"telefono"}, []string{"telefono"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missingFields(tt.result, tt.fields); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missingFields = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
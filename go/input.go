@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Cédula leída de la entrada junto con su ubicación de origen
+type InputRecord struct {
+	Cedula string
+	Source string
+	// Texto de la cédula tal como venía en la entrada, antes de normalizarla
+	Original string
+	// Columnas adicionales de la entrada que se copian a la salida, por nombre
+	Meta map[string]string
+}
+
+// Leer cédulas según el origen: "-" es la entrada estándar, .txt una cédula
+// por línea y cualquier otro archivo se trata como Excel. column (encabezado
+// de la columna de cédulas) y passthrough (columnas que se copian a la
+// salida) solo aplican a Excel
+func readInputs(input, column string, passthrough []string) ([]InputRecord, error) {
+	if excelInput(input) {
+		return readCedulasFromExcel(input, column, passthrough)
+	}
+	if len(passthrough) > 0 {
+		return nil, fmt.Errorf("las columnas adicionales solo se pueden leer de archivos Excel")
+	}
+	if input == "-" {
+		return readCedulasFromReader(os.Stdin, "stdin")
+	}
+	return readCedulasFromText(input)
+}
+
+// Igual que readInputs, pero entregando cada cédula a fn apenas se lee. Solo
+// los archivos Excel se leen fila por fila; el resto se lee completo
+func streamInputs(input, column string, passthrough []string, fn func(InputRecord)) error {
+	if excelInput(input) {
+		return streamCedulasFromExcel(input, column, passthrough, fn)
+	}
+	records, err := readInputs(input, column, passthrough)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		fn(record)
+	}
+	return nil
+}
+
+func excelInput(input string) bool {
+	return input != "-" && !strings.EqualFold(filepath.Ext(input), ".txt")
+}
+
+// Leer cédulas de un archivo de texto, una por línea, ignorando líneas vacías
+func readCedulasFromText(filename string) ([]InputRecord, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo archivo de texto: %v", err)
+	}
+	defer f.Close()
+
+	return readCedulasFromReader(f, filepath.Base(filename))
+}
+
+// El origen de cada cédula queda como nombre:línea
+func readCedulasFromReader(r io.Reader, name string) ([]InputRecord, error) {
+	var cedulas []InputRecord
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		original := strings.TrimSpace(scanner.Text())
+		if cedula := normalizeCedula(original); cedula != "" {
+			cedulas = append(cedulas, InputRecord{Cedula: cedula, Source: fmt.Sprintf("%s:%d", name, line), Original: original})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error leyendo cédulas: %v", err)
+	}
+
+	return cedulas, nil
+}
+
+// Índice de la columna cuyo encabezado coincide con name, sin distinguir
+// mayúsculas, tildes ni espacios alrededor ("cedula" encuentra "Cédula")
+func findHeaderColumn(headers []string, name string) (int, error) {
+	want := foldHeader(name)
+	for i, header := range headers {
+		if foldHeader(header) == want {
+			return i, nil
+		}
+	}
+
+	available := make([]string, 0, len(headers))
+	for _, header := range headers {
+		if header = strings.TrimSpace(header); header != "" {
+			available = append(available, fmt.Sprintf("%q", header))
+		}
+	}
+	return 0, fmt.Errorf("no se encontró la columna %q; encabezados disponibles: %s", name, strings.Join(available, ", "))
+}
+
+var accentReplacer = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+)
+
+// Minúsculas y sin tildes, para comparar encabezados y mensajes de la DIAN
+func foldHeader(header string) string {
+	return accentReplacer.Replace(strings.ToLower(strings.TrimSpace(header)))
+}
+
+// Límite de filas de entrada por ejecución; superarlo sin -force suele ser
+// un error (archivo equivocado) y conviene dividir el archivo en lotes
+func checkInputRows(rows, max int) error {
+	if max <= 0 || rows <= max {
+		return nil
+	}
+	return fmt.Errorf("la entrada tiene más de %d filas (límite -max-input-rows); divida el archivo en partes o use -force si es intencional", max)
+}
+
+// Cédulas desde el lote start (1 = el primero) en lotes de size cédulas. Los
+// límites de los lotes dependen solo del orden de la entrada y de size
+func skipBatches(in []InputRecord, size, start int) ([]InputRecord, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("se necesita -batch-size mayor que cero")
+	}
+	batches := (len(in) + size - 1) / size
+	if start < 1 || start > batches {
+		return nil, fmt.Errorf("lote %d fuera de rango: la entrada tiene %d lotes de %d cédulas", start, batches, size)
+	}
+	return in[(start-1)*size:], nil
+}
+
+// Quitar los separadores que se escriben a mano o que agrega el formato de
+// la celda: espacios (incluido el no separable), puntos y comas.
+// "1.012.345.678" queda "1012345678"
+func normalizeCedula(value string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '.' || r == ',' {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// Completar con ceros a la izquierda una cédula de solo dígitos hasta width
+// caracteres (NIT y documentos extranjeros que los requieren). width <= 0 o
+// valores no numéricos se dejan igual
+func padCedula(cedula string, width int) string {
+	if width <= 0 || len(cedula) >= width || strings.Trim(cedula, "0123456789") != "" {
+		return cedula
+	}
+	return strings.Repeat("0", width-len(cedula)) + cedula
+}
+
+// Lista simple de cédulas (listas de inclusión/exclusión), completadas a
+// width igual que la entrada para que coincidan con ella
+func readCedulaList(filename string, width int) ([]string, error) {
+	records, err := readCedulasFromText(filename)
+	if err != nil {
+		return nil, err
+	}
+	cedulas := make([]string, len(records))
+	for i, record := range records {
+		cedulas[i] = padCedula(record.Cedula, width)
+	}
+	return cedulas, nil
+}
+
+// Lista de inclusión. Un archivo sin cédulas es un error: una lista explícita
+// vacía no significa "procesar todo"
+func readIncludeList(filename string, width int) ([]string, error) {
+	cedulas, err := readCedulaList(filename, width)
+	if err != nil {
+		return nil, err
+	}
+	if len(cedulas) == 0 {
+		return nil, fmt.Errorf("la lista de inclusión %s no tiene cédulas", filename)
+	}
+	return cedulas, nil
+}
+
+// Aplicar lista de inclusión y de exclusión. Una lista de inclusión vacía
+// no restringe; la exclusión siempre tiene prioridad
+func filterCedulas(in, include, exclude []string) []string {
+	allowed := cedulaFilter(include, exclude)
+	out := make([]string, 0, len(in))
+	for _, cedula := range in {
+		if allowed(cedula) {
+			out = append(out, cedula)
+		}
+	}
+	return out
+}
+
+// Las mismas reglas que filterCedulas para una cédula a la vez, para filtrar
+// la entrada mientras se lee
+func cedulaFilter(include, exclude []string) func(string) bool {
+	includeSet := make(map[string]bool, len(include))
+	for _, cedula := range include {
+		includeSet[cedula] = true
+	}
+	excludeSet := make(map[string]bool, len(exclude))
+	for _, cedula := range exclude {
+		excludeSet[cedula] = true
+	}
+
+	return func(cedula string) bool {
+		if len(includeSet) > 0 && !includeSet[cedula] {
+			return false
+		}
+		return !excludeSet[cedula]
+	}
+}
+
+// Igual que filterCedulas, conservando el origen de cada cédula
+func filterInputs(in []InputRecord, include, exclude []string) []InputRecord {
+	cedulas := make([]string, len(in))
+	for i, record := range in {
+		cedulas[i] = record.Cedula
+	}
+	kept := filterCedulas(cedulas, include, exclude)
+
+	// filterCedulas conserva el orden y decide solo por el número, así que
+	// basta con recorrer ambas listas a la vez
+	out := make([]InputRecord, 0, len(kept))
+	for _, record := range in {
+		if len(out) < len(kept) && record.Cedula == kept[len(out)] {
+			out = append(out, record)
+		}
+	}
+	return out
+}
+
+// Elegir n cédulas al azar de toda la entrada (no solo las primeras),
+// conservando su orden original. La misma semilla da la misma muestra
+func sampleInputs(in []InputRecord, n int, seed int64) []InputRecord {
+	indices := sampleIndices(len(in), n, seed)
+	out := make([]InputRecord, len(indices))
+	for i, idx := range indices {
+		out[i] = in[idx]
+	}
+	return out
+}
+
+func sampleIndices(total, n int, seed int64) []int {
+	if n >= total {
+		n = total
+	}
+	if n <= 0 {
+		return nil
+	}
+	indices := rand.New(rand.NewSource(seed)).Perm(total)[:n]
+	sort.Ints(indices)
+	return indices
+}
@@ -0,0 +1,646 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func inputRecords(cedulas ...string) []InputRecord {
+	records := make([]InputRecord, len(cedulas))
+	for i, cedula := range cedulas {
+		records[i] = InputRecord{Cedula: cedula}
+	}
+	return records
+}
+
+func recordCedulas(records []InputRecord) []string {
+	cedulas := make([]string, len(records))
+	for i, record := range records {
+		cedulas[i] = record.Cedula
+	}
+	return cedulas
+}
+
+func writeTempFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Archivo Excel de entrada con las filas dadas en la primera hoja ("Hoja1")
+func writeExcelInput(t *testing.T, rows [][]interface{}) string {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetSheetName("Sheet1", "Hoja1"); err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow("Hoja1", cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "entrada.xlsx")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFilterCedulas(t *testing.T) {
+	in := []string{"1", "2", "3", "4"}
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{"sin listas", nil, nil, []string{"1", "2", "3", "4"}},
+		{"solo inclusión", []string{"2", "4", "9"}, nil, []string{"2", "4"}},
+		{"solo exclusión", nil, []string{"1", "3"}, []string{"2", "4"}},
+		{"ambas", []string{"1", "2", "3"}, []string{"2"}, []string{"1", "3"}},
+		{"la exclusión tiene prioridad", []string{"1"}, []string{"1"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterCedulas(in, tt.include, tt.exclude)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterCedulas = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterInputs(t *testing.T) {
+	// Cédula repetida en dos filas: cada fila conserva su origen
+	in := []InputRecord{
+		{Cedula: "1", Source: "a.txt:1"},
+		{Cedula: "2", Source: "a.txt:2"},
+		{Cedula: "1", Source: "a.txt:3"},
+		{Cedula: "3", Source: "a.txt:4"},
+	}
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string // orígenes que quedan
+	}{
+		{"sin listas", nil, nil, []string{"a.txt:1", "a.txt:2", "a.txt:3", "a.txt:4"}},
+		{"solo inclusión", []string{"1"}, nil, []string{"a.txt:1", "a.txt:3"}},
+		{"solo exclusión", nil, []string{"1"}, []string{"a.txt:2", "a.txt:4"}},
+		{"ambas", []string{"1", "3"}, []string{"3"}, []string{"a.txt:1", "a.txt:3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filterInputs(in, tt.include, tt.exclude)
+			got := make([]string, len(out))
+			for i, record := range out {
+				got[i] = record.Source
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterInputs = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCedulaList(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		width   int
+		want    []string
+	}{
+		{"tal cual", "1012345678\n\n 98765 \n", 0, []string{"1012345678", "98765"}},
+		{"completa con ceros", "12345\n0012345\nAB12\n", 7, []string{"0012345", "0012345", "AB12"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTempFile(t, "lista.txt", tt.content)
+			got, err := readCedulaList(path, tt.width)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readCedulaList = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadIncludeList(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{"una por línea", "1012345678\n\n 98765 \n", []string{"1012345678", "98765"}, false},
+		{"archivo vacío", "", nil, true},
+		{"solo líneas en blanco", "\n  \n\t\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTempFile(t, "incluir.txt", tt.content)
+			got, err := readIncludeList(path, 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readIncludeList: error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readIncludeList = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := readIncludeList(filepath.Join(t.TempDir(), "no-existe.txt"), 0); err == nil {
+		t.Error("se esperaba error con un archivo inexistente")
+	}
+}
+
+func TestReadCedulasFromReader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []InputRecord
+	}{
+		{"vacía", "", nil},
+		{"líneas vacías", "\n  \n", nil},
+		{
+			name:  "origen por línea",
+			input: "1012345678\n\n 1.012.345.679 \r\n",
+			want: []InputRecord{
+				{Cedula: "1012345678", Source: "stdin:1", Original: "1012345678"},
+				{Cedula: "1012345679", Source: "stdin:3", Original: "1.012.345.679"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readCedulasFromReader(strings.NewReader(tt.input), "stdin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readCedulasFromReader = %+v, se esperaba %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadInputsStdin(t *testing.T) {
+	stdin := os.Stdin
+	t.Cleanup(func() { os.Stdin = stdin })
+	f, err := os.Open(writeTempFile(t, "entrada.txt", "111\n222\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	os.Stdin = f
+
+	records, err := readInputs("-", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []InputRecord{{Cedula: "111", Source: "stdin:1", Original: "111"}, {Cedula: "222", Source: "stdin:2", Original: "222"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("readInputs = %+v, se esperaba %+v", records, want)
+	}
+}
+
+func TestInputSource(t *testing.T) {
+	tests := []struct {
+		name string
+		path func(t *testing.T) string
+		want []string
+	}{
+		{
+			name: "texto",
+			path: func(t *testing.T) string { return writeTempFile(t, "cedulas.txt", "111\n\n222\n") },
+			want: []string{"cedulas.txt:1", "cedulas.txt:3"},
+		},
+		{
+			name: "excel",
+			path: func(t *testing.T) string {
+				return writeExcelInput(t, [][]interface{}{{"Cedula"}, {"111"}, {""}, {"222"}})
+			},
+			want: []string{"entrada.xlsx:Hoja1:2", "entrada.xlsx:Hoja1:4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := readInputs(tt.path(t), "", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, len(records))
+			for i, record := range records {
+				got[i] = record.Source
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orígenes = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSampleInputs(t *testing.T) {
+	in := inputRecords("1", "2", "3", "4", "5", "6", "7", "8", "9", "10")
+	tests := []struct {
+		name    string
+		n       int
+		wantLen int
+	}{
+		{"cero", 0, 0},
+		{"negativo", -1, 0},
+		{"parte", 4, 4},
+		{"igual al total", 10, 10},
+		{"mayor que el total", 50, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sampleInputs(in, tt.n, 42)
+			if len(got) != tt.wantLen {
+				t.Fatalf("%d cédulas, se esperaban %d", len(got), tt.wantLen)
+			}
+			// Se conserva el orden de la entrada y no hay repetidas
+			pos := make(map[string]int, len(in))
+			for i, record := range in {
+				pos[record.Cedula] = i
+			}
+			for i := 1; i < len(got); i++ {
+				if pos[got[i].Cedula] <= pos[got[i-1].Cedula] {
+					t.Errorf("muestra fuera de orden o repetida: %v", recordCedulas(got))
+					break
+				}
+			}
+			// La misma semilla da la misma muestra
+			if again := sampleInputs(in, tt.n, 42); !reflect.DeepEqual(recordCedulas(again), recordCedulas(got)) {
+				t.Errorf("con la misma semilla: %v y %v", recordCedulas(got), recordCedulas(again))
+			}
+		})
+	}
+}
+
+func TestSampleInputsCoversWholeInput(t *testing.T) {
+	in := make([]InputRecord, 1000)
+	for i := range in {
+		in[i] = InputRecord{Cedula: strconv.Itoa(i)}
+	}
+	// Con 1000 cédulas una muestra de 20 casi seguro incluye alguna de la
+	// segunda mitad; la semilla fija hace la prueba determinista
+	sample := sampleInputs(in, 20, 7)
+	last, _ := strconv.Atoi(sample[len(sample)-1].Cedula)
+	if last < 500 {
+		t.Errorf("la muestra solo tomó cédulas del principio: %v", recordCedulas(sample))
+	}
+}
+
+func TestCheckInputRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    int
+		max     int
+		wantErr bool
+	}{
+		{"bajo el límite", 10, 100, false},
+		{"justo en el límite", 100, 100, false},
+		{"sobre el límite", 101, 100, true},
+		{"sin límite", 1000000, 0, false},
+		{"límite negativo", 5, -1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkInputRows(tt.rows, tt.max)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkInputRows(%d, %d) = %v, se esperaba error: %v", tt.rows, tt.max, err, tt.wantErr)
+			}
+			// El mensaje sugiere dividir la entrada o usar -force
+			if err != nil && (!strings.Contains(err.Error(), "-force") || !strings.Contains(err.Error(), "divida")) {
+				t.Errorf("mensaje poco claro: %v", err)
+			}
+		})
+	}
+}
+
+// Una entrada más grande que el límite se detecta después de leerla
+func TestCheckInputRowsOverInput(t *testing.T) {
+	path := writeTempFile(t, "cedulas.txt", strings.Join(testCedulas(6), "\n"))
+	records, err := readInputs(path, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkInputRows(len(records), 5); err == nil {
+		t.Errorf("%d filas con límite 5 no dieron error", len(records))
+	}
+	if err := checkInputRows(len(records), 6); err != nil {
+		t.Errorf("%d filas con límite 6: %v", len(records), err)
+	}
+}
+
+func TestReadPassthroughColumns(t *testing.T) {
+	excel := writeExcelInput(t, [][]interface{}{
+		{"Cédula", "Cliente", "Lote"},
+		{"1012345678", " ACME ", "enero"},
+		{"1023456789", "Globex"}, // fila corta: sin lote
+	})
+	tests := []struct {
+		name        string
+		input       string
+		passthrough []string
+		want        []map[string]string
+		wantErr     bool
+	}{
+		{
+			name:        "una columna",
+			input:       excel,
+			passthrough: []string{"cliente"},
+			want:        []map[string]string{{"cliente": "ACME"}, {"cliente": "Globex"}},
+		},
+		{
+			name:        "celda faltante",
+			input:       excel,
+			passthrough: []string{"cliente", "lote"},
+			want:        []map[string]string{{"cliente": "ACME", "lote": "enero"}, {"cliente": "Globex", "lote": ""}},
+		},
+		{name: "sin columnas", input: excel, want: []map[string]string{nil, nil}},
+		{name: "columna inexistente", input: excel, passthrough: []string{"sucursal"}, wantErr: true},
+		{name: "entrada de texto", input: writeTempFile(t, "cedulas.txt", "1012345678\n"), passthrough: []string{"cliente"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := readInputs(tt.input, "cedula", tt.passthrough)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readInputs: error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := make([]map[string]string, len(records))
+			for i, record := range records {
+				got[i] = record.Meta
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Meta = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipBatches(t *testing.T) {
+	records := make([]InputRecord, 10)
+	for i, cedula := range testCedulas(10) {
+		records[i] = InputRecord{Cedula: cedula}
+	}
+	tests := []struct {
+		name      string
+		size      int
+		start     int
+		wantFirst string
+		wantLen   int
+		wantErr   bool
+	}{
+		{name: "primer lote", size: 3, start: 1, wantFirst: "1000", wantLen: 10},
+		{name: "lote intermedio", size: 3, start: 2, wantFirst: "1003", wantLen: 7},
+		// El último lote queda incompleto: 10 cédulas en lotes de 3
+		{name: "último lote", size: 3, start: 4, wantFirst: "1009", wantLen: 1},
+		{name: "lote inexistente", size: 3, start: 5, wantErr: true},
+		{name: "lote cero", size: 3, start: 0, wantErr: true},
+		{name: "sin tamaño de lote", size: 0, start: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := skipBatches(records, tt.size, tt.start)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("skipBatches: error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != tt.wantLen || got[0].Cedula != tt.wantFirst {
+				t.Errorf("%d cédulas desde %s, se esperaban %d desde %s", len(got), got[0].Cedula, tt.wantLen, tt.wantFirst)
+			}
+		})
+	}
+}
+
+// Retomar desde un lote consulta solo desde la primera cédula de ese lote
+func TestStartBatchProcessing(t *testing.T) {
+	records := make([]InputRecord, 9)
+	for i, cedula := range testCedulas(9) {
+		records[i] = InputRecord{Cedula: cedula}
+	}
+	config := testConfig()
+	config.BatchSize = 3
+	var mu sync.Mutex
+	var queried []string
+	s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+		mu.Lock()
+		queried = append(queried, cedula)
+		mu.Unlock()
+		return okResult(cedula, attempt)
+	})
+
+	rest, err := skipBatches(records, config.BatchSize, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := s.ProcessInputs(rest)
+	sort.Strings(queried)
+	if want := []string{"1006", "1007", "1008"}; !reflect.DeepEqual(queried, want) {
+		t.Errorf("cédulas consultadas %v, se esperaban %v", queried, want)
+	}
+	if len(results) != 3 {
+		t.Errorf("%d resultados, se esperaban 3", len(results))
+	}
+}
+
+func TestFindHeaderColumn(t *testing.T) {
+	headers := []string{"Nombre", " Cédula ", "DOCUMENTO", "", "Año"}
+	tests := []struct {
+		name    string
+		want    int
+		wantErr bool
+	}{
+		{"Cédula", 1, false},
+		{"cedula", 1, false},
+		{"CÉDULA", 1, false},
+		{"  cédula", 1, false},
+		{"documento", 2, false},
+		{"ano", 4, false},
+		{"nit", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findHeaderColumn(headers, tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				// El error lista los encabezados disponibles, sin los vacíos
+				if msg := err.Error(); !strings.Contains(msg, `"Nombre", "Cédula", "DOCUMENTO", "Año"`) {
+					t.Errorf("el error no lista los encabezados: %s", msg)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("findHeaderColumn(%q) = %d, se esperaba %d", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeCedula(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"1012345678", "1012345678"},
+		{"1.012.345.678", "1012345678"},
+		{" 79 123\u00a0456 ", "79123456"},
+		{"52,000,111", "52000111"},
+		{"0012345", "0012345"},
+		{"E-12345", "E-12345"},
+		{" . ", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeCedula(tt.value); got != tt.want {
+			t.Errorf("normalizeCedula(%q) = %q, se esperaba %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPadCedula(t *testing.T) {
+	tests := []struct {
+		cedula string
+		width  int
+		want   string
+	}{
+		{"12345", 0, "12345"},
+		{"12345", 8, "00012345"},
+		{"0012345", 7, "0012345"},
+		{"1012345678", 8, "1012345678"},
+		{"AB12", 8, "AB12"},
+		{"12-3", 8, "12-3"},
+		{"", 3, "000"},
+	}
+	for _, tt := range tests {
+		if got := padCedula(tt.cedula, tt.width); got != tt.want {
+			t.Errorf("padCedula(%q, %d) = %q, se esperaba %q", tt.cedula, tt.width, got, tt.want)
+		}
+	}
+}
+
+// Los ceros a la izquierda de celdas de texto y de formatos numéricos con
+// ceros llegan sin cambios hasta la consulta
+func TestLeadingZerosEndToEnd(t *testing.T) {
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	f.SetCellValue(sheet, "A1", "Cedula")
+	f.SetCellStr(sheet, "A2", "0012345")
+	f.SetCellValue(sheet, "A3", 12345)
+	numFmt := "0000000000"
+	style, err := f.NewStyle(&excelize.Style{CustomNumFmt: &numFmt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SetCellStyle(sheet, "A3", "A3", style)
+	path := filepath.Join(t.TempDir(), "ceros.xlsx")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	inputs, err := readCedulasFromExcel(path, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"0012345", "0000012345"}
+	if got := recordCedulas(inputs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("cédulas leídas = %v, se esperaba %v", got, want)
+	}
+
+	var mu sync.Mutex
+	var queried []string
+	s := newTestScraper(t, testConfig(), func(cedula string, attempt int) Result {
+		mu.Lock()
+		queried = append(queried, cedula)
+		mu.Unlock()
+		return okResult(cedula, attempt)
+	})
+	results := s.ProcessInputs(inputs)
+	sort.Strings(queried) // Los workers consultan en cualquier orden
+	if wantQueried := []string{"0000012345", "0012345"}; !reflect.DeepEqual(queried, wantQueried) {
+		t.Errorf("cédulas consultadas = %v, se esperaba %v", queried, wantQueried)
+	}
+	for i, result := range results {
+		if result.Cedula != want[i] {
+			t.Errorf("resultado %d: cédula %q, se esperaba %q", i, result.Cedula, want[i])
+		}
+	}
+}
+
+func TestStreamCedulasFromExcel(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   [][]interface{}
+		column string
+		want   []string
+	}{
+		{
+			name: "columna A",
+			rows: [][]interface{}{{"Cedula"}, {"1012345678"}, {" 79.123.456 "}, {nil}, {"52000111"}},
+			want: []string{"1012345678", "79123456", "52000111"},
+		},
+		{
+			name: "celdas numéricas",
+			rows: [][]interface{}{{"Cedula"}, {1012345678}, {79123456.0}},
+			want: []string{"1012345678", "79123456"},
+		},
+		{
+			name:   "columna por encabezado",
+			rows:   [][]interface{}{{"Nombre", "Cédula"}, {"JUAN", "111"}, {"ANA", "222"}},
+			column: "cedula",
+			want:   []string{"111", "222"},
+		},
+		{
+			name:   "encabezado en mayúsculas con tilde",
+			rows:   [][]interface{}{{"Nombre", "NÚMERO DOCUMENTO"}, {"JUAN", "333"}},
+			column: "Número Documento",
+			want:   []string{"333"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeExcelInput(t, tt.rows)
+			var got []string
+			err := streamCedulasFromExcel(path, tt.column, nil, func(record InputRecord) {
+				got = append(got, record.Cedula)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cédulas = %v, se esperaba %v", got, tt.want)
+			}
+			// Lo mismo que entrega la lectura completa
+			records, err := readInputs(path, tt.column, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if all := recordCedulas(records); !reflect.DeepEqual(got, all) {
+				t.Errorf("streaming = %v, lectura completa = %v", got, all)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	line := strings.Repeat("x", 9) + "\n" // 10 bytes
+	tests := []struct {
+		name        string
+		maxSize     int64
+		maxBackups  int
+		maxAge      time.Duration
+		oldBackup   bool // respaldo de hace una semana antes de empezar
+		writes      int
+		wantBackups int
+		wantSize    int64
+	}{
+		{name: "sin límite de tamaño", maxSize: 0, writes: 10, wantBackups: 0, wantSize: 100},
+		{name: "rota al superar el tamaño", maxSize: 30, writes: 7, wantBackups: 2, wantSize: 10},
+		{name: "conserva maxBackups", maxSize: 10, maxBackups: 2, writes: 6, wantBackups: 2, wantSize: 10},
+		{name: "borra respaldos viejos", maxSize: 30, maxAge: 24 * time.Hour, oldBackup: true, writes: 4, wantBackups: 1, wantSize: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "logs", "ejecucion.log")
+			if tt.oldBackup {
+				old := filename + ".20000101-000000.000"
+				if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(old, []byte(line), 0644); err != nil {
+					t.Fatal(err)
+				}
+				week := time.Now().Add(-7 * 24 * time.Hour)
+				if err := os.Chtimes(old, week, week); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r, err := newRotatingFile(filename, tt.maxSize, tt.maxBackups, tt.maxAge)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.writes; i++ {
+				if _, err := r.Write([]byte(line)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			backups, _ := filepath.Glob(filename + ".*")
+			if len(backups) != tt.wantBackups {
+				t.Errorf("%d respaldos (%v), se esperaban %d", len(backups), backups, tt.wantBackups)
+			}
+			info, err := os.Stat(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != tt.wantSize {
+				t.Errorf("tamaño del log actual = %d, se esperaba %d", info.Size(), tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "ejecucion.log")
+	if err := os.WriteFile(filename, []byte("anterior\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := newRotatingFile(filename, 1024, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("nueva\n"))
+	r.Close()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "anterior\nnueva\n" {
+		t.Errorf("contenido = %q, se esperaba que se agregara al log existente", data)
+	}
+}
@@ -0,0 +1,2817 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// Configuración para probar el procesamiento sin navegador: un worker y sin
+// pausas entre reintentos
+func testConfig() Config {
+	config := getDefaultConfig()
+	config.APIKey = ""
+	config.Concurrency = 2
+	config.MaxParallelBrowsers = 1
+	config.BatchSize = 0
+	config.TimeoutConfig.RetryDelay = time.Millisecond
+	return config
+}
+
+// Scraper cuyo navegador arranca siempre y cuyas consultas responde query
+func newTestScraper(t *testing.T, config Config, query func(cedula string, attempt int) Result) *Scraper {
+	t.Helper()
+	s, err := NewScraper(config)
+	if err != nil {
+		t.Fatalf("NewScraper: %v", err)
+	}
+	s.launch = func(context.Context) error { return nil }
+	s.query = func(cedula string, _ context.Context, attempt int) Result {
+		result := query(cedula, attempt)
+		result.Cedula = cedula
+		result.Attempts = attempt
+		return result
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Sink que guarda en memoria lo que recibe
+type memorySink struct {
+	mu      sync.Mutex
+	results []Result
+	closed  bool
+}
+
+func (m *memorySink) Write(result Result) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results = append(m.results, result)
+	return nil
+}
+
+func (m *memorySink) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+func (m *memorySink) cedulas() map[string]Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byCedula := make(map[string]Result, len(m.results))
+	for _, result := range m.results {
+		byCedula[result.Cedula] = result
+	}
+	return byCedula
+}
+
+func okResult(string, int) Result {
+	return Result{Estado: "REGISTRO ACTIVO", PrimerNombre: "JUAN", PrimerApellido: "PEREZ"}
+}
+
+func errorResult(string, int) Result {
+	return Result{Estado: "Error", Error: "Error de navegación"}
+}
+
+func testCedulas(n int) []string {
+	cedulas := make([]string, n)
+	for i := range cedulas {
+		cedulas[i] = fmt.Sprintf("%d", 1000+i)
+	}
+	return cedulas
+}
+
+func countEstado(results []Result, estado string) int {
+	n := 0
+	for _, result := range results {
+		if result.Estado == estado {
+			n++
+		}
+	}
+	return n
+}
+
+func TestNormalizeFecha(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"vacía", "", ""},
+		{"solo espacios", "   ", ""},
+		{"dd/mm/aaaa", "05/03/2015", "2015-03-05"},
+		{"sin ceros", "5/3/2015", "2015-03-05"},
+		{"con guiones", "05-03-2015", "2015-03-05"},
+		{"ISO", "2015-03-05", "2015-03-05"},
+		{"aaaa/mm/dd", "2015/03/05", "2015-03-05"},
+		{"con hora", "05/03/2015 10:20:30", "2015-03-05"},
+		{"ISO con hora", "2015-03-05 10:20:30", "2015-03-05"},
+		{"espacios alrededor", " 05/03/2015\n", "2015-03-05"},
+		{"no reconocida", "marzo de 2015", "marzo de 2015"},
+		{"fecha imposible", "31/02/2015", "31/02/2015"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeFecha(tt.raw); got != tt.want {
+				t.Errorf("normalizeFecha(%q) = %q, se esperaba %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPingbackURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		callback string
+		server   bool
+		want     string
+	}{
+		{"sin pingback", "", false, ""},
+		{"con pingback", "https://ejemplo.com/pb?token=t", true, "https://ejemplo.com/pb?token=t"},
+		// Sin servidor escuchando no sirve pedirle a 2captcha que avise
+		{"servidor sin iniciar", "https://ejemplo.com/pb", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scraper{pingbackCallback: tt.callback}
+			if tt.server {
+				s.pingback = &pingbackServer{}
+			}
+			if got := s.pingbackURL(); got != tt.want {
+				t.Errorf("pingbackURL = %q, se esperaba %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxConsecutiveErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		max      int
+		failures func(i int) bool // la cédula i falla
+		wantHalt bool
+	}{
+		{"sin límite", 0, func(int) bool { return true }, false},
+		{"todas fallan", 3, func(int) bool { return true }, true},
+		{"un éxito reinicia la cuenta", 3, func(i int) bool { return i%3 != 2 }, false},
+		{"errores al final", 3, func(i int) bool { return i >= 5 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MaxConsecutiveErrors = tt.max
+			config.TimeoutConfig.MaxRetries = 1
+			cedulas := testCedulas(20)
+			index := make(map[string]int)
+			for i, cedula := range cedulas {
+				index[cedula] = i
+			}
+			s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+				// Tiempo para que el recolector cuente el resultado anterior
+				time.Sleep(2 * time.Millisecond)
+				if tt.failures(index[cedula]) {
+					return errorResult(cedula, attempt)
+				}
+				return okResult(cedula, attempt)
+			})
+
+			results := s.ProcessCedulas(cedulas)
+			if len(results) != len(cedulas) {
+				t.Fatalf("%d resultados, se esperaban %d", len(results), len(cedulas))
+			}
+			halted := s.stopReason != ""
+			if halted != tt.wantHalt {
+				t.Fatalf("detenido = %v (%q), se esperaba %v", halted, s.stopReason, tt.wantHalt)
+			}
+			pending := countEstado(results, "Pendiente")
+			if !tt.wantHalt {
+				if pending != 0 {
+					t.Errorf("%d cédulas pendientes sin haberse detenido", pending)
+				}
+				return
+			}
+			if !strings.Contains(s.stopReason, "errores consecutivos") {
+				t.Errorf("motivo = %q", s.stopReason)
+			}
+			if pending == 0 {
+				t.Error("se detuvo pero no quedaron cédulas pendientes")
+			}
+			for _, result := range results {
+				if result.Estado == "Pendiente" && !strings.Contains(result.Error, "errores consecutivos") {
+					t.Errorf("pendiente %s sin el motivo: %q", result.Cedula, result.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestSinkReceivesEveryResult(t *testing.T) {
+	tests := []struct {
+		name string
+		halt bool
+	}{
+		{"completo", false},
+		{"detenido", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.TimeoutConfig.MaxRetries = 1
+			sink := &memorySink{}
+			config.Sink = sink
+			query := okResult
+			if tt.halt {
+				config.MaxConsecutiveErrors = 2
+				query = func(cedula string, attempt int) Result {
+					time.Sleep(2 * time.Millisecond)
+					return errorResult(cedula, attempt)
+				}
+			}
+			s := newTestScraper(t, config, query)
+
+			cedulas := testCedulas(12)
+			results := s.ProcessCedulas(cedulas)
+			got := sink.cedulas()
+			if len(got) != len(cedulas) {
+				t.Fatalf("el sink recibió %d cédulas, se esperaban %d", len(got), len(cedulas))
+			}
+			for _, result := range results {
+				if got[result.Cedula].Estado != result.Estado {
+					t.Errorf("cédula %s: sink %q, salida %q", result.Cedula, got[result.Cedula].Estado, result.Estado)
+				}
+			}
+			if tt.halt && countEstado(sink.results, "Pendiente") == 0 {
+				t.Error("el sink no recibió las cédulas pendientes")
+			}
+		})
+	}
+}
+
+func TestWaitPageReady(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFixtureServer(t)
+
+	tests := []struct {
+		name     string
+		selector string
+		wantErr  bool
+	}{
+		{"aparece tras la carga", getDefaultConfig().PageReadySelector, false},
+		{"nunca aparece", `//*[@id="noExiste"]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scraper{config: getDefaultConfig()}
+			s.config.PageReadySelector = tt.selector
+			s.config.TimeoutConfig.PageLoad = 2 * time.Second
+
+			err := chromedp.Run(ctx, chromedp.Navigate(srv.URL+"/carga_lenta.html"), s.waitPageReady())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("waitPageReady: error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWaitSearchReady(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFixtureServer(t)
+
+	tests := []struct {
+		name    string
+		listo   string
+		imagen  string // CaptchaImageSelector; vacío usa el valor por defecto
+		wantErr bool
+	}{
+		{"se habilita el botón", "boton", "", false},
+		{"aparece el captcha", "captcha", "", false},
+		{"captcha con selector propio", "captchaseparado", `//*[@id="captchaImagen"]//img`, false},
+		{"captcha fuera del selector", "captchaseparado", "", true},
+		{"no cambia", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scraper{config: getDefaultConfig()}
+			s.config.TimeoutConfig.PageLoad = time.Second
+			if tt.imagen != "" {
+				s.config.CaptchaImageSelector = tt.imagen
+			}
+
+			if err := chromedp.Run(ctx,
+				chromedp.Navigate(srv.URL+"/busqueda.html?listo="+tt.listo),
+				chromedp.WaitReady("body", chromedp.ByQuery),
+			); err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			err := chromedp.Run(ctx, s.waitSearchReady())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("waitSearchReady: error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			// Sin pausa fija: continúa poco después de que la página está lista
+			if !tt.wantErr && time.Since(start) > 900*time.Millisecond {
+				t.Errorf("tardó %v en continuar", time.Since(start))
+			}
+		})
+	}
+}
+
+func TestResultBuffer(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		adds        int
+		wantBatches []int // tamaño de cada lote enviado, incluido el flush final
+	}{
+		{"sin buffer", 0, 3, []int{1, 1, 1}},
+		{"de uno", 1, 2, []int{1, 1}},
+		{"lotes completos", 2, 4, []int{2, 2}},
+		{"resto al final", 3, 4, []int{3, 1}},
+		{"nada que enviar", 3, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := make(chan []Result, tt.adds+1)
+			s := &Scraper{config: Config{ResultBufferSize: tt.size}, results: out}
+			b := s.newResultBuffer()
+			for _, cedula := range testCedulas(tt.adds) {
+				b.add(Result{Cedula: cedula})
+			}
+			b.flush()
+			close(out)
+
+			var got []int
+			for batch := range out {
+				got = append(got, len(batch))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantBatches) {
+				t.Errorf("lotes %v, se esperaba %v", got, tt.wantBatches)
+			}
+		})
+	}
+}
+
+func TestProcessWithResultBuffer(t *testing.T) {
+	for _, size := range []int{1, 3, 50} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			config := testConfig()
+			config.ResultBufferSize = size
+			config.MaxParallelBrowsers = 3
+			s := newTestScraper(t, config, okResult)
+
+			cedulas := testCedulas(10)
+			results := s.ProcessCedulas(cedulas)
+			if len(results) != len(cedulas) {
+				t.Fatalf("%d resultados, se esperaban %d", len(results), len(cedulas))
+			}
+			for i, result := range results {
+				if result.Cedula != cedulas[i] || result.Estado != "REGISTRO ACTIVO" {
+					t.Errorf("resultado %d: %s %q", i, result.Cedula, result.Estado)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteResultsToExcelSheets(t *testing.T) {
+	tests := []struct {
+		name       string
+		results    []Result
+		summary    bool
+		wantSheets []string // la primera es la hoja activa
+	}{
+		{"sin resultados", nil, false, []string{"Results"}},
+		{"con resultados", []Result{{Cedula: "1012345678", PrimerNombre: "JUAN", Estado: "REGISTRO ACTIVO"}}, false, []string{"Results"}},
+		{"con resumen", []Result{{Cedula: "1012345678", PrimerNombre: "JUAN", Estado: "REGISTRO ACTIVO"}}, true, []string{summarySheetName, "Results"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "resultados.xlsx")
+			if err := writeResultsToExcel(path, tt.results, OutputOptions{SummarySheet: tt.summary}); err != nil {
+				t.Fatal(err)
+			}
+			f, err := excelize.OpenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			// Sin la "Sheet1" vacía de excelize
+			if got := f.GetSheetList(); !reflect.DeepEqual(got, tt.wantSheets) {
+				t.Errorf("hojas %v, se esperaba %v", got, tt.wantSheets)
+			}
+			if got := f.GetSheetName(f.GetActiveSheetIndex()); got != tt.wantSheets[0] {
+				t.Errorf("hoja activa %q, se esperaba %s", got, tt.wantSheets[0])
+			}
+			rows, err := f.GetRows("Results")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(tt.results)+1 {
+				t.Errorf("filas de Results: %v", rows)
+			}
+		})
+	}
+}
+
+func TestWriteResultsToExcelStream(t *testing.T) {
+	tests := []struct {
+		name string
+		rows int
+	}{
+		{"en memoria", 3},
+		{"en el límite", excelStreamThreshold},
+		{"con StreamWriter", excelStreamThreshold + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make([]Result, tt.rows)
+			for i := range results {
+				results[i] = Result{Cedula: fmt.Sprintf("%010d", i), PrimerNombre: "JUAN", Estado: "REGISTRO ACTIVO", Attempts: 1}
+			}
+			path := filepath.Join(t.TempDir(), "resultados.xlsx")
+			if err := writeResultsToExcel(path, results, OutputOptions{SummarySheet: true}); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readResultsFromExcel(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.rows {
+				t.Fatalf("%d filas, se esperaban %d", len(got), tt.rows)
+			}
+			for _, i := range []int{0, tt.rows - 1} {
+				if got[i].Cedula != results[i].Cedula || got[i].Estado != "REGISTRO ACTIVO" || got[i].Attempts != 1 {
+					t.Errorf("fila %d = %+v, se esperaba %+v", i, got[i], results[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMaxCaptchasPerCedula(t *testing.T) {
+	tests := []struct {
+		name         string
+		limit        int
+		maxRetries   int
+		wantCaptchas int
+		wantCode     string
+	}{
+		{"corta en el límite", 3, 10, 3, errCodeCaptchaLimit},
+		{"límite de uno", 1, 10, 1, errCodeCaptchaLimit},
+		{"sin límite agota los reintentos", 0, 4, 4, ""},
+		{"reintentos antes que el límite", 5, 2, 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MaxCaptchasPerCedula = tt.limit
+			config.TimeoutConfig.MaxRetries = tt.maxRetries
+			s := newTestScraper(t, config, func(string, int) Result {
+				// La DIAN rechaza siempre el texto del captcha
+				return Result{Estado: "Error", Error: "La DIAN rechazó el captcha", Captchas: 1}
+			})
+
+			results := s.ProcessCedulas([]string{"1012345678"})
+			if len(results) != 1 {
+				t.Fatalf("%d resultados", len(results))
+			}
+			result := results[0]
+			if result.Captchas != tt.wantCaptchas {
+				t.Errorf("Captchas = %d, se esperaba %d", result.Captchas, tt.wantCaptchas)
+			}
+			if result.ErrorCode != tt.wantCode {
+				t.Errorf("ErrorCode = %q, se esperaba %q", result.ErrorCode, tt.wantCode)
+			}
+			if result.Error == "" {
+				t.Error("el resultado no quedó con error")
+			}
+		})
+	}
+}
+
+func TestScreenshotOnSuccess(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+
+	tests := []struct {
+		name       string
+		escenario  string
+		screenshot bool
+		wantFile   bool
+	}{
+		{"éxito sin la opción", "exito", false, false},
+		{"éxito con la opción", "exito", true, true},
+		{"error con la opción", "error", true, false},
+		// Sin RUT no es una consulta exitosa: no se captura
+		{"sin RUT con la opción", "sinrut", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := browserTestConfig()
+			config.ScreenshotOnSuccess = tt.screenshot
+			s := newBrowserScraper(t, config, srv, "escenario="+tt.escenario)
+
+			result := s.processCedula("1012345678", ctx, 1)
+			if tt.wantFile && result.Error != "" {
+				t.Fatalf("la consulta falló: %s (%s)", result.Error, result.ErrorCode)
+			}
+			png, ok := config.ArtifactStore.(*memoryArtifactStore).get("resultado_1012345678.png")
+			if ok != tt.wantFile {
+				t.Fatalf("captura guardada = %v, se esperaba %v", ok, tt.wantFile)
+			}
+			if ok && !bytes.HasPrefix(png, []byte("\x89PNG")) {
+				t.Error("la captura no es un PNG")
+			}
+		})
+	}
+}
+
+func TestWaitClickable(t *testing.T) {
+	ctx := newTestBrowser(t)
+
+	tests := []struct {
+		name    string
+		button  string
+		script  string
+		wantErr bool
+	}{
+		{"habilitado", `<button id="b">Buscar</button>`, "", false},
+		{"se habilita después", `<button id="b" disabled>Buscar</button>`,
+			`setTimeout(() => document.getElementById("b").disabled = false, 300)`, false},
+		{"aria-disabled", `<button id="b" aria-disabled="true">Buscar</button>`, "", true},
+		{"clase de PrimeFaces", `<button id="b" class="ui-button ui-state-disabled">Buscar</button>`, "", true},
+		{"deshabilitado", `<button id="b" disabled>Buscar</button>`, "", true},
+		{"no existe", `<p>sin botón</p>`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := "data:text/html," + url.PathEscape(tt.button+"<script>"+tt.script+"</script>")
+			if err := chromedp.Run(ctx, chromedp.Navigate(page), chromedp.WaitReady("body", chromedp.ByQuery)); err != nil {
+				t.Fatal(err)
+			}
+			// Los casos que fallan agotarían buttonEnableTimeout: basta con
+			// comprobar que no se da el botón por habilitado
+			waitCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+			err := chromedp.Run(waitCtx, waitClickable(`//*[@id="b"]`))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("waitClickable: error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessCedulaCaptcha(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+
+	tests := []struct {
+		name         string
+		query        string
+		configure    func(*Config)
+		answer       string
+		wantEstado   string
+		wantCaptchas int
+		wantReport   string // reporte enviado a 2captcha
+	}{
+		// Buscar se habilita al escribir el captcha
+		{name: "respuesta correcta", query: "escenario=captcha", answer: "abc12", wantEstado: "REGISTRO ACTIVO", wantCaptchas: 1},
+		{name: "respuesta rechazada", query: "escenario=captcha", answer: "zzz99", wantEstado: "Error", wantCaptchas: 1, wantReport: "reportbad"},
+		// Sin imagen de captcha no se envía nada a 2captcha
+		{name: "sin captcha", query: "escenario=exito", answer: "abc12", wantEstado: "REGISTRO ACTIVO"},
+		{
+			name:  "imagen y campo separados",
+			query: "escenario=captchaseparado",
+			configure: func(c *Config) {
+				c.CaptchaImageSelector = `//*[@id="captchaImagen"]//img`
+				c.CaptchaInputSelector = `//*[@id="captchaTexto"]`
+			},
+			answer:       "abc12",
+			wantEstado:   "REGISTRO ACTIVO",
+			wantCaptchas: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := browserTestConfig()
+			if tt.configure != nil {
+				tt.configure(&config)
+			}
+			s := newBrowserScraper(t, config, srv, tt.query)
+			fake := withFakeCaptcha(t, s, tt.answer)
+
+			result := s.processCedula("1012345678", ctx, 1)
+			if result.Estado != tt.wantEstado {
+				t.Fatalf("Estado = %q (%s), se esperaba %q", result.Estado, result.Error, tt.wantEstado)
+			}
+			if result.CaptchaRequired != (tt.wantCaptchas > 0) || result.Captchas != tt.wantCaptchas {
+				t.Errorf("captcha: requerido %v, %d resueltos; se esperaban %d", result.CaptchaRequired, result.Captchas, tt.wantCaptchas)
+			}
+			if tt.wantCaptchas == 0 && len(fake.all()) > 0 {
+				t.Errorf("%d peticiones a 2captcha sin captcha en la página", len(fake.all()))
+			}
+			var report string
+			for _, form := range fake.all() {
+				if action := form.Get("action"); action != "" {
+					report = action
+				}
+			}
+			if report != tt.wantReport {
+				t.Errorf("reporte a 2captcha = %q, se esperaba %q", report, tt.wantReport)
+			}
+		})
+	}
+}
+
+// ERROR_CAPTCHA_UNSOLVABLE hace capturar y enviar de nuevo el captcha dentro
+// del mismo intento, hasta UnsolvableRecaptures veces
+func TestUnsolvableRecapture(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+
+	tests := []struct {
+		name         string
+		unsolvable   int // respuestas ilegibles antes de la correcta
+		recaptures   int
+		wantEstado   string
+		wantCaptchas int
+	}{
+		{"legible a la primera", 0, 2, "REGISTRO ACTIVO", 1},
+		{"ilegible una vez", 1, 2, "REGISTRO ACTIVO", 2},
+		{"sin recapturas", 1, 0, "Error", 1},
+		{"recapturas agotadas", 2, 1, "Error", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := browserTestConfig()
+			config.UnsolvableRecaptures = tt.recaptures
+			s := newBrowserScraper(t, config, srv, "escenario=captcha")
+
+			pingback, err := startPingbackServer("127.0.0.1:0", "t0k")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.pingback = pingback
+			s.pingbackCallback = "http://127.0.0.1/pingback?token=t0k"
+			var ids atomic.Int64
+			_, client := newFakeTwoCaptcha(t, func(form url.Values) string {
+				if form.Get("action") != "" {
+					return `{"status":1,"request":"OK_REPORT_RECORDED"}`
+				}
+				n := ids.Add(1)
+				id := strconv.FormatInt(n, 10)
+				if n <= int64(tt.unsolvable) {
+					pingback.deliver(id, "ERROR_CAPTCHA_UNSOLVABLE")
+				} else {
+					pingback.deliver(id, "abc12")
+				}
+				return `{"status":1,"request":"` + id + `"}`
+			})
+			s.captcha = client
+
+			result := s.processCedula("1012345678", ctx, 1)
+			if result.Estado != tt.wantEstado {
+				t.Fatalf("Estado = %q (%s), se esperaba %q", result.Estado, result.Error, tt.wantEstado)
+			}
+			if result.Captchas != tt.wantCaptchas {
+				t.Errorf("%d captchas enviados, se esperaban %d", result.Captchas, tt.wantCaptchas)
+			}
+			if submits := ids.Load(); submits != int64(tt.wantCaptchas) {
+				t.Errorf("%d envíos a 2captcha, se esperaban %d", submits, tt.wantCaptchas)
+			}
+			if tt.wantEstado == "Error" && !strings.Contains(result.Error, "ERROR_CAPTCHA_UNSOLVABLE") {
+				t.Errorf("Error = %q, se esperaba ERROR_CAPTCHA_UNSOLVABLE", result.Error)
+			}
+		})
+	}
+}
+
+// Con RecordCaptchaIDs el resultado guarda el ID de cada solicitud a 2captcha
+func TestRecordCaptchaIDs(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+
+	tests := []struct {
+		name    string
+		query   string
+		record  bool
+		wantIDs []string
+	}{
+		{"captcha registrado", "escenario=captcha", true, []string{"1"}},
+		{"sin registrar", "escenario=captcha", false, nil},
+		{"sin captcha", "escenario=exito", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := browserTestConfig()
+			config.RecordCaptchaIDs = tt.record
+			s := newBrowserScraper(t, config, srv, tt.query)
+			withFakeCaptcha(t, s, "abc12")
+
+			result := s.processCedula("1012345678", ctx, 1)
+			if result.Estado != "REGISTRO ACTIVO" {
+				t.Fatalf("Estado = %q (%s)", result.Estado, result.Error)
+			}
+			if !reflect.DeepEqual(result.CaptchaIDs, tt.wantIDs) {
+				t.Errorf("CaptchaIDs = %q, se esperaba %q", result.CaptchaIDs, tt.wantIDs)
+			}
+		})
+	}
+}
+
+// Los IDs de todos los intentos se acumulan y solo aparecen en el JSON si hay
+func TestCaptchaIDsAcrossAttempts(t *testing.T) {
+	config := testConfig()
+	config.RecordCaptchaIDs = true
+	s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+		id := fmt.Sprintf("id%d", attempt)
+		if attempt == 1 {
+			return Result{Estado: "Error", Error: "Error con captcha: respuesta rechazada", Captchas: 1, CaptchaIDs: []string{id}}
+		}
+		return Result{Estado: "REGISTRO ACTIVO", Captchas: 1, CaptchaIDs: []string{id}}
+	})
+
+	results := s.ProcessCedulas([]string{"1000"})
+	if want := []string{"id1", "id2"}; !reflect.DeepEqual(results[0].CaptchaIDs, want) {
+		t.Errorf("CaptchaIDs = %q, se esperaba %q", results[0].CaptchaIDs, want)
+	}
+
+	for _, tt := range []struct {
+		ids  []string
+		want bool
+	}{{[]string{"id1"}, true}, {nil, false}} {
+		data, err := json.Marshal(Result{Cedula: "1000", CaptchaIDs: tt.ids})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bytes.Contains(data, []byte(`"captchaIds"`)); got != tt.want {
+			t.Errorf("JSON con IDs %q: captchaIds presente %v, se esperaba %v", tt.ids, got, tt.want)
+		}
+	}
+}
+
+// Con AudioCaptchaFallback el primer intento usa la imagen y los reintentos
+// el audio que ofrece la página, si lo hay
+func TestProcessCedulaAudioCaptcha(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+	audio, err := os.ReadFile(filepath.Join("testdata", "captcha.mp3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		fallback  bool
+		attempt   int
+		wantAudio bool
+	}{
+		{"primer intento con imagen", "escenario=captchaaudio", true, 1, false},
+		{"reintento con audio", "escenario=captchaaudio", true, 2, true},
+		{"audio deshabilitado", "escenario=captchaaudio", false, 2, false},
+		{"página sin audio", "escenario=captcha", true, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := browserTestConfig()
+			config.AudioCaptchaFallback = tt.fallback
+			s := newBrowserScraper(t, config, srv, tt.query)
+			fake := withFakeCaptcha(t, s, "abc12")
+
+			result := s.processCedula("1012345678", ctx, tt.attempt)
+			if result.Estado != "REGISTRO ACTIVO" {
+				t.Fatalf("Estado = %q (%s), se esperaba %q", result.Estado, result.Error, "REGISTRO ACTIVO")
+			}
+			forms := fake.all()
+			if len(forms) != 1 {
+				t.Fatalf("%d envíos a 2captcha, se esperaba 1", len(forms))
+			}
+			if gotAudio := forms[0].Get("method") == "audio"; gotAudio != tt.wantAudio {
+				t.Fatalf("envío de audio: %v, se esperaba %v (method %q)", gotAudio, tt.wantAudio, forms[0].Get("method"))
+			}
+			if tt.wantAudio {
+				if body := forms[0].Get("body"); body != base64.StdEncoding.EncodeToString(audio) {
+					t.Errorf("el audio enviado no coincide con el de la página (%d caracteres)", len(body))
+				}
+				if lang := forms[0].Get("lang"); lang != config.AudioCaptchaLang {
+					t.Errorf("lang = %q, se esperaba %q", lang, config.AudioCaptchaLang)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadAudioCaptcha(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+	audio, err := os.ReadFile(filepath.Join("testdata", "captcha.mp3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		sel     string
+		wantErr bool
+	}{
+		{"audio de la página", `//*[@id="captchaAudio"]`, false},
+		{"sin elemento", `//*[@id="noExiste"]`, true},
+		{"sin src", `//*[@id="captchaTexto"]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tabCtx, cancel := chromedp.NewContext(ctx)
+			defer cancel()
+			if err := chromedp.Run(tabCtx, chromedp.Navigate(srv.URL+"/consulta?escenario=captchaaudio")); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := downloadAudioCaptcha(tabCtx, tt.sel)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, audio) {
+				t.Errorf("audio de %d bytes, se esperaban los %d de testdata/captcha.mp3", len(got), len(audio))
+			}
+		})
+	}
+}
+
+func TestIsFileLocked(t *testing.T) {
+	windows := runtime.GOOS == "windows"
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"permiso denegado", fs.ErrPermission, false},
+		{"permiso denegado al abrir", &fs.PathError{Op: "open", Path: "r.xlsx", Err: fs.ErrPermission}, false},
+		{"EBUSY", &fs.PathError{Op: "open", Path: "r.xlsx", Err: syscall.EBUSY}, !windows},
+		{"EAGAIN", &fs.PathError{Op: "write", Path: "r.xlsx", Err: syscall.EAGAIN}, !windows},
+		{"uso compartido en Windows", &fs.PathError{Op: "open", Path: "r.xlsx", Err: errSharingViolation}, windows},
+		{"bloqueo en Windows", &fs.PathError{Op: "open", Path: "r.xlsx", Err: errLockViolation}, windows},
+		{"mensaje de uso compartido", errors.New("The process cannot access the file because it is being used by another process."), true},
+		{"mensaje de recurso ocupado", errors.New("open r.xlsx: resource busy"), true},
+		{"no existe", fs.ErrNotExist, false},
+		{"zip inválido", errors.New("zip: not a valid zip file"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFileLocked(tt.err); got != tt.want {
+				t.Errorf("isFileLocked(%v) = %v, se esperaba %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryLockedFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error // error de cada llamada; después de agotarlos, nil
+		wantCalls int
+		wantErr   bool
+	}{
+		{"sin error", nil, 1, false},
+		{"bloqueado una vez", []error{errors.New("open r.xlsx: resource busy")}, 2, false},
+		{"permiso denegado no se reintenta", []error{fs.ErrPermission}, 1, true},
+		{"otro error no se reintenta", []error{fs.ErrNotExist}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retryLockedFile("r.xlsx", func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("%d llamadas, se esperaban %d", calls, tt.wantCalls)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Consultas contra la copia local de la DIAN, un escenario por caso (ver
+// testdata/dian.html)
+func TestProcessCedulaScenarios(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+
+	tests := []struct {
+		name       string
+		query      string
+		configure  func(*Config)
+		wantEstado string
+		wantCode   string
+	}{
+		{name: "éxito", query: "escenario=exito", wantEstado: "REGISTRO ACTIVO"},
+		// Buscar lleva a la vista de detalle, con otros IDs
+		{name: "vista de detalle", query: "escenario=detalle", wantEstado: "REGISTRO ACTIVO"},
+		{name: "sesión concurrente", query: "escenario=sesion", wantEstado: "Error", wantCode: errCodeSessionLimit},
+		// Sin RUT es un resultado, no un error
+		{name: "sin RUT", query: "escenario=sinrut", wantEstado: estadoSinRUT},
+		{name: "error de la DIAN", query: "escenario=error", wantEstado: "Error"},
+		{name: "demasiados intentos", query: "escenario=limite", wantEstado: "RateLimited", wantCode: errCodeRateLimited},
+		{name: "campos obligatorios vacíos", query: "escenario=blanco", wantEstado: estadoIncompleto, wantCode: errCodeIncomplete},
+		// Sin captcha, sin mensaje y sin datos tras la búsqueda
+		{name: "bloqueo silencioso", query: "escenario=bloqueo", wantEstado: "Error", wantCode: errCodeSilentBlock},
+		{
+			name:       "bloqueo silencioso sin detección",
+			query:      "escenario=bloqueo",
+			configure:  func(c *Config) { c.DetectSilentBlock = false },
+			wantEstado: estadoIncompleto,
+			wantCode:   errCodeIncomplete,
+		},
+		{
+			name:       "estado en blanco incompleto",
+			query:      "escenario=blanco",
+			configure:  func(c *Config) { c.RequiredFields = []string{"primerNombre"} },
+			wantEstado: estadoIncompleto,
+			wantCode:   errCodeIncomplete,
+		},
+		{
+			name:  "estado en blanco sin datos",
+			query: "escenario=blanco",
+			configure: func(c *Config) {
+				c.RequiredFields = []string{"primerNombre"}
+				c.EmptyEstadoIncomplete = false
+			},
+			wantEstado: "",
+		},
+		{
+			name:  "campos tardíos con reintento",
+			query: "escenario=tardio",
+			configure: func(c *Config) {
+				c.ExtractionRetries = 3
+				c.DetectSilentBlock = false
+				c.TimeoutConfig.DataExtraction = time.Second
+			},
+			wantEstado: "REGISTRO ACTIVO",
+		},
+		// La capa de carga sigue visible después del ajax: se espera a que se vaya
+		{name: "indicador de carga", query: "escenario=spinner", wantEstado: "REGISTRO ACTIVO"},
+		{
+			name:  "indicador de carga sin esperarlo",
+			query: "escenario=spinner",
+			configure: func(c *Config) {
+				c.SpinnerSelector = ""
+				c.DetectSilentBlock = false
+				c.TimeoutConfig.DataExtraction = 300 * time.Millisecond
+			},
+			// Los datos se leen antes de que la página los muestre
+			wantEstado: estadoIncompleto,
+			wantCode:   errCodeIncomplete,
+		},
+		{
+			name:       "indicador de carga que no se va",
+			query:      "escenario=spinner",
+			configure:  func(c *Config) { c.SpinnerTimeout = 300 * time.Millisecond },
+			wantEstado: "Error",
+		},
+		{
+			name:       "campos tardíos sin reintento",
+			query:      "escenario=tardio",
+			configure:  func(c *Config) { c.DetectSilentBlock = false; c.TimeoutConfig.DataExtraction = time.Second },
+			wantEstado: "Error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := browserTestConfig()
+			if tt.configure != nil {
+				tt.configure(&config)
+			}
+			s := newBrowserScraper(t, config, srv, tt.query)
+
+			result := s.processCedula("1012345678", ctx, 1)
+			if result.Estado != tt.wantEstado || result.ErrorCode != tt.wantCode {
+				t.Errorf("Estado %q, ErrorCode %q (%s); se esperaba %q, %q",
+					result.Estado, result.ErrorCode, result.Error, tt.wantEstado, tt.wantCode)
+			}
+			if result.ProcessingTime == "" {
+				t.Error("falta ProcessingTime")
+			}
+		})
+	}
+}
+
+// La espera termina cuando la capa de carga se oculta o se quita del DOM
+func TestWaitSpinnerGone(t *testing.T) {
+	ctx := newTestBrowser(t)
+
+	const delay = 500 * time.Millisecond
+	tests := []struct {
+		name    string
+		body    string
+		timeout time.Duration
+		wantErr bool
+		wantMin time.Duration
+	}{
+		{name: "sin indicador", body: `<p>listo</p>`, timeout: 2 * time.Second},
+		{name: "indicador oculto", body: `<div class="ui-blockui" style="display:none"></div>`, timeout: 2 * time.Second},
+		{name: "se quita del DOM", body: `<div class="ui-blockui" id="capa">cargando</div>
+<script>setTimeout(() => document.getElementById("capa").remove(), 500)</script>`, timeout: 2 * time.Second, wantMin: delay},
+		{name: "se oculta", body: `<div class="ui-blockui" id="capa">cargando</div>
+<script>setTimeout(() => document.getElementById("capa").style.visibility = "hidden", 500)</script>`, timeout: 2 * time.Second, wantMin: delay},
+		{name: "sigue visible", body: `<div class="ui-blockui">cargando</div>`, timeout: 300 * time.Millisecond, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "<html><body>"+tt.body+"</body></html>")
+			}))
+			defer srv.Close()
+			config := testConfig()
+			config.SpinnerTimeout = tt.timeout
+			s := newTestScraper(t, config, nil)
+
+			tabCtx, cancel := chromedp.NewContext(ctx)
+			defer cancel()
+			if err := chromedp.Run(tabCtx, chromedp.Navigate(srv.URL)); err != nil {
+				t.Fatal(err)
+			}
+			begin := time.Now()
+			err := chromedp.Run(tabCtx, chromedp.ActionFunc(s.waitSpinnerGone))
+			elapsed := time.Since(begin)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("waitSpinnerGone: error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if elapsed < tt.wantMin-100*time.Millisecond || elapsed > tt.timeout+time.Second {
+				t.Errorf("esperó %v, se esperaba entre %v y %v", elapsed, tt.wantMin, tt.timeout)
+			}
+		})
+	}
+}
+
+// El código HTTP del documento queda en el resultado y un 403/429 se
+// clasifica como bloqueo aunque la página cargue
+func TestProcessCedulaHTTPStatus(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+
+	tests := []struct {
+		name       string
+		status     int
+		wantEstado string
+		wantCode   string
+	}{
+		{"200", http.StatusOK, "REGISTRO ACTIVO", ""},
+		{"429 demasiadas peticiones", http.StatusTooManyRequests, "RateLimited", errCodeRateLimited},
+		{"403 prohibido", http.StatusForbidden, "Error", errCodeBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := newBrowserScraper(t, browserTestConfig(), srv, fmt.Sprintf("escenario=exito&status=%d", tt.status))
+
+			result := s.processCedula("1012345678", ctx, 1)
+			if result.HTTPStatus != tt.status {
+				t.Errorf("HTTPStatus = %d, se esperaba %d", result.HTTPStatus, tt.status)
+			}
+			if result.Estado != tt.wantEstado || result.ErrorCode != tt.wantCode {
+				t.Errorf("Estado %q, ErrorCode %q (%s); se esperaba %q, %q",
+					result.Estado, result.ErrorCode, result.Error, tt.wantEstado, tt.wantCode)
+			}
+		})
+	}
+}
+
+// Pestañas abiertas a la vez con MaxTotalTabs, contando las páginas del
+// navegador mientras corren varias consultas
+func TestMaxTotalTabs(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+
+	countPages := func() int {
+		targets, err := chromedp.Targets(ctx)
+		if err != nil {
+			return 0
+		}
+		n := 0
+		for _, info := range targets {
+			if info.Type == "page" {
+				n++
+			}
+		}
+		return n
+	}
+	baseline := countPages() // la pestaña inicial de newTestBrowser
+
+	tests := []struct {
+		name    string
+		maxTabs int
+		queries int
+		wantMax int
+	}{
+		{"con límite", 2, 4, 2},
+		{"sin límite", 0, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := browserTestConfig()
+			config.MaxTotalTabs = tt.maxTabs
+			s := newBrowserScraper(t, config, srv, "escenario=exito")
+
+			var maxOpen atomic.Int32
+			done := make(chan struct{})
+			go func() {
+				ticker := time.NewTicker(100 * time.Millisecond)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-done:
+						return
+					case <-ticker.C:
+						if n := int32(countPages() - baseline); n > maxOpen.Load() {
+							maxOpen.Store(n)
+						}
+					}
+				}
+			}()
+
+			var wg sync.WaitGroup
+			for i := 0; i < tt.queries; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					if result := s.processCedula(fmt.Sprintf("10%d", i), ctx, 1); result.Estado != "REGISTRO ACTIVO" {
+						t.Errorf("Estado = %q (%s)", result.Estado, result.Error)
+					}
+				}(i)
+			}
+			wg.Wait()
+			close(done)
+
+			if got := int(maxOpen.Load()); got != tt.wantMax {
+				t.Errorf("hasta %d pestañas abiertas a la vez, se esperaban %d", got, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestWarmupNavigation(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+
+	var visits atomic.Int32
+	home := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			visits.Add(1)
+		}
+		io.WriteString(w, "<html><body>Portal DIAN</body></html>")
+	}))
+	t.Cleanup(home.Close)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name       string
+		warmup     bool
+		homeURL    string
+		wantEstado string
+		wantVisits int32
+	}{
+		{name: "desactivada", warmup: false, homeURL: home.URL + "/", wantEstado: "REGISTRO ACTIVO", wantVisits: 0},
+		{name: "activada", warmup: true, homeURL: home.URL + "/", wantEstado: "REGISTRO ACTIVO", wantVisits: 1},
+		{name: "portal inaccesible", warmup: true, homeURL: closed.URL + "/", wantEstado: "Error", wantVisits: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visits.Store(0)
+			config := browserTestConfig()
+			config.WarmupNavigation = tt.warmup
+			s := newBrowserScraper(t, config, srv, "escenario=exito")
+			s.homeURL = tt.homeURL
+
+			result := s.processCedula("1012345678", ctx, 1)
+			if result.Estado != tt.wantEstado {
+				t.Errorf("Estado = %q (%s), se esperaba %q", result.Estado, result.Error, tt.wantEstado)
+			}
+			if got := visits.Load(); got != tt.wantVisits {
+				t.Errorf("visitas al portal = %d, se esperaban %d", got, tt.wantVisits)
+			}
+		})
+	}
+}
+
+func TestBrowserProfile(t *testing.T) {
+	ctx := newTestBrowser(t)
+
+	var mu sync.Mutex
+	var received http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			mu.Lock()
+			received = r.Header.Clone()
+			mu.Unlock()
+		}
+		io.WriteString(w, "<html><body>DIAN</body></html>")
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		timezone       string
+		extraHeaders   map[string]string
+	}{
+		// Chrome descarta un Referer https hacia el servidor http de prueba
+		{"colombiano", "es-CO,es;q=0.9", "America/Bogota", map[string]string{"Referer": srv.URL + "/inicio"}},
+		{"otro perfil", "en-US", "Europe/Madrid", map[string]string{"X-Gateway-Token": "abc"}},
+		{"sin encabezados extra", "es-CO", "America/Bogota", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.AcceptLanguage = tt.acceptLanguage
+			config.Timezone = tt.timezone
+			config.ExtraHeaders = tt.extraHeaders
+			s := newTestScraper(t, config, nil)
+
+			tabCtx, cancel := chromedp.NewContext(ctx)
+			defer cancel()
+			var timezone string
+			err := chromedp.Run(tabCtx,
+				s.browserProfile(),
+				s.navigate(srv.URL+"/"),
+				chromedp.Evaluate(`Intl.DateTimeFormat().resolvedOptions().timeZone`, &timezone),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			got := received
+			mu.Unlock()
+			if got.Get("Accept-Language") != tt.acceptLanguage {
+				t.Errorf("Accept-Language = %q, se esperaba %q", got.Get("Accept-Language"), tt.acceptLanguage)
+			}
+			for name, want := range tt.extraHeaders {
+				if got.Get(name) != want {
+					t.Errorf("%s = %q, se esperaba %q", name, got.Get(name), want)
+				}
+			}
+			if timezone != tt.timezone {
+				t.Errorf("zona horaria = %q, se esperaba %q", timezone, tt.timezone)
+			}
+		})
+	}
+}
+
+func TestExtraHeaders(t *testing.T) {
+	tests := []struct {
+		name           string
+		extraHeaders   map[string]string
+		acceptLanguage string
+		want           network.Headers
+	}{
+		{"por defecto", map[string]string{"Referer": dianHomeURL}, "es-CO,es;q=0.9",
+			network.Headers{"Referer": dianHomeURL, "Accept-Language": "es-CO,es;q=0.9"}},
+		// AcceptLanguage prevalece sobre un Accept-Language de ExtraHeaders
+		{"idioma repetido", map[string]string{"Accept-Language": "en-US"}, "es-CO",
+			network.Headers{"Accept-Language": "es-CO"}},
+		{"sin idioma", map[string]string{"X-Token": "abc"}, "", network.Headers{"X-Token": "abc"}},
+		{"ninguno", nil, "", network.Headers{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.ExtraHeaders = tt.extraHeaders
+			config.AcceptLanguage = tt.acceptLanguage
+			s := newTestScraper(t, config, nil)
+			if got := s.extraHeaders(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extraHeaders = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferrer(t *testing.T) {
+	tests := []struct {
+		extraHeaders map[string]string
+		want         string
+	}{
+		{map[string]string{"Referer": dianHomeURL}, dianHomeURL},
+		{map[string]string{"referer": "https://example.com/", "X-Token": "abc"}, "https://example.com/"},
+		{map[string]string{"X-Token": "abc"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		config := testConfig()
+		config.ExtraHeaders = tt.extraHeaders
+		s := newTestScraper(t, config, nil)
+		if got := s.referrer(); got != tt.want {
+			t.Errorf("referrer con %v = %q, se esperaba %q", tt.extraHeaders, got, tt.want)
+		}
+	}
+}
+
+func TestApplyHeaderFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		want  map[string]string
+	}{
+		{"sin flags", nil, map[string]string{"Referer": dianHomeURL}},
+		{"nuevo encabezado", []string{"X-Gateway-Token: abc"}, map[string]string{"Referer": dianHomeURL, "X-Gateway-Token": "abc"}},
+		// El nombre no distingue mayúsculas: reemplaza el Referer por defecto
+		{"reemplazo", []string{"referer: https://example.com/"}, map[string]string{"referer": "https://example.com/"}},
+		{"quitar por defecto", []string{"Referer:"}, map[string]string{}},
+		{"valor con dos puntos", []string{"X-Url: http://proxy:8080"}, map[string]string{"Referer": dianHomeURL, "X-Url": "http://proxy:8080"}},
+		{"el último gana", []string{"X-A: 1", "x-a: 2"}, map[string]string{"Referer": dianHomeURL, "x-a": "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{"Referer": dianHomeURL}
+			applyHeaderFlags(headers, tt.flags)
+			if !reflect.DeepEqual(headers, tt.want) {
+				t.Errorf("encabezados = %v, se esperaba %v", headers, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryIncomplete(t *testing.T) {
+	tests := []struct {
+		name         string
+		retry        bool
+		incomplete   int // intentos seguidos con campos faltantes
+		wantEstado   string
+		wantAttempts int
+	}{
+		{"sin reintento", false, 1, estadoIncompleto, 1},
+		{"se completa", true, 1, "REGISTRO ACTIVO", 2},
+		{"agota los reintentos", true, 5, estadoIncompleto, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.RetryIncomplete = tt.retry
+			config.TimeoutConfig.MaxRetries = 3
+			s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+				if attempt <= tt.incomplete {
+					return Result{Estado: estadoIncompleto, Error: "Faltan campos: estado", ErrorCode: errCodeIncomplete}
+				}
+				return okResult(cedula, attempt)
+			})
+
+			results := s.ProcessCedulas([]string{"1012345678"})
+			if results[0].Estado != tt.wantEstado || results[0].Attempts != tt.wantAttempts {
+				t.Errorf("Estado %q en el intento %d; se esperaba %q en el %d",
+					results[0].Estado, results[0].Attempts, tt.wantEstado, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestBatchCooldown(t *testing.T) {
+	tests := []struct {
+		name        string
+		batchSize   int
+		cooldown    time.Duration
+		halt        bool // detener durante la pausa entre lotes
+		wantGap     time.Duration
+		wantPending int
+	}{
+		{name: "sin pausa", batchSize: 2, cooldown: 0},
+		{name: "con pausa", batchSize: 2, cooldown: 100 * time.Millisecond, wantGap: 100 * time.Millisecond},
+		{name: "detenido durante la pausa", batchSize: 2, cooldown: time.Minute, halt: true, wantPending: 2},
+		// Por defecto no hay lotes y la pausa no se aplica
+		{name: "sin lotes", batchSize: getDefaultConfig().BatchSize, cooldown: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Concurrency = 1
+			config.BatchSize = tt.batchSize
+			config.BatchCooldown = tt.cooldown
+
+			var mu sync.Mutex
+			started := make(map[string]time.Time)
+			var s *Scraper
+			s = newTestScraper(t, config, func(cedula string, attempt int) Result {
+				mu.Lock()
+				started[cedula] = time.Now()
+				mu.Unlock()
+				if tt.halt && cedula == "1001" {
+					// La pausa empieza después de este lote
+					go func() {
+						time.Sleep(20 * time.Millisecond)
+						s.halt("prueba")
+					}()
+				}
+				return okResult(cedula, attempt)
+			})
+
+			start := time.Now()
+			results := s.ProcessCedulas(testCedulas(4))
+			if tt.cooldown == time.Minute && time.Since(start) > 10*time.Second {
+				t.Error("el procesamiento esperó la pausa entre lotes")
+			}
+			if n := countEstado(results, "Pendiente"); n != tt.wantPending {
+				t.Errorf("%d cédulas pendientes, se esperaban %d", n, tt.wantPending)
+			}
+			if tt.wantGap > 0 {
+				if gap := started["1002"].Sub(started["1001"]); gap < tt.wantGap {
+					t.Errorf("pausa entre lotes de %v, se esperaba al menos %v", gap, tt.wantGap)
+				}
+			}
+		})
+	}
+}
+
+func TestResultSource(t *testing.T) {
+	s := newTestScraper(t, testConfig(), func(cedula string, attempt int) Result {
+		if cedula == "222" {
+			return errorResult(cedula, attempt)
+		}
+		return okResult(cedula, attempt)
+	})
+	inputs := []InputRecord{
+		{Cedula: "111", Source: "entrada.xlsx:Hoja1:2"},
+		{Cedula: "222", Source: "entrada.xlsx:Hoja1:3"},
+	}
+
+	results := s.ProcessInputs(inputs)
+	for i, result := range results {
+		if result.Source != inputs[i].Source {
+			t.Errorf("cédula %s: Source = %q, se esperaba %q", result.Cedula, result.Source, inputs[i].Source)
+		}
+	}
+}
+
+func TestDurationFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{durationRaw, "1.23456s"},
+		{durationSeconds, "1.23s"},
+		{durationNumeric, "1.23"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			config := testConfig()
+			config.DurationFormat = tt.format
+			s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+				result := okResult(cedula, attempt)
+				result.ProcessingTime = "1.23456s"
+				return result
+			})
+
+			results := s.ProcessCedulas([]string{"1012345678"})
+			if got := results[0].ProcessingTime; got != tt.want {
+				t.Errorf("ProcessingTime = %q, se esperaba %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultTransformer(t *testing.T) {
+	tests := []struct {
+		name        string
+		transformer func(Result) (Result, bool)
+		wantCedulas []string
+		wantNombre  string
+	}{
+		{
+			name:        "sin transformador",
+			wantCedulas: []string{"1000", "1001", "1002"},
+			wantNombre:  "JUAN",
+		},
+		{
+			name: "redactar",
+			transformer: func(r Result) (Result, bool) {
+				r.PrimerNombre = "***"
+				return r, true
+			},
+			wantCedulas: []string{"1000", "1001", "1002"},
+			wantNombre:  "***",
+		},
+		{
+			name:        "descartar errores",
+			transformer: func(r Result) (Result, bool) { return r, r.Error == "" },
+			wantCedulas: []string{"1000", "1002"},
+			wantNombre:  "JUAN",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.ResultTransformer = tt.transformer
+			sink := &memorySink{}
+			config.Sink = sink
+			s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+				if cedula == "1001" {
+					return errorResult(cedula, attempt)
+				}
+				return okResult(cedula, attempt)
+			})
+
+			results := s.ProcessCedulas(testCedulas(3))
+			var got []string
+			for _, result := range results {
+				got = append(got, result.Cedula)
+				if result.Error == "" && result.PrimerNombre != tt.wantNombre {
+					t.Errorf("cédula %s: PrimerNombre = %q, se esperaba %q", result.Cedula, result.PrimerNombre, tt.wantNombre)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantCedulas) {
+				t.Errorf("cédulas en la salida = %v, se esperaba %v", got, tt.wantCedulas)
+			}
+			// El sink recibe lo mismo que la salida
+			received := sink.cedulas()
+			if len(received) != len(tt.wantCedulas) {
+				t.Errorf("el sink recibió %d resultados, se esperaban %d", len(received), len(tt.wantCedulas))
+			}
+			if got := received["1000"].PrimerNombre; got != tt.wantNombre {
+				t.Errorf("PrimerNombre en el sink = %q, se esperaba %q", got, tt.wantNombre)
+			}
+		})
+	}
+}
+
+func TestProcessCedulaRedirect(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+	// "localhost" es otro host para checkHost aunque sea el mismo servidor
+	otherHost := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantEstado string
+		wantCode   string
+	}{
+		{"mismo host", srv.URL + "/consulta", "REGISTRO ACTIVO", ""},
+		{"otro host", otherHost + "/consulta", "Error", errCodeRedirected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := newBrowserScraper(t, browserTestConfig(), srv, "")
+			s.consultURL = srv.URL + "/redirigir?a=" + url.QueryEscape(tt.target)
+
+			result := s.processCedula("1012345678", ctx, 1)
+			if result.Estado != tt.wantEstado || result.ErrorCode != tt.wantCode {
+				t.Errorf("Estado %q, ErrorCode %q (%s); se esperaba %q, %q",
+					result.Estado, result.ErrorCode, result.Error, tt.wantEstado, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestProcessCedulaMultipleMatches(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+
+	// La copia local muestra el registro principal y dos más (ver datos.json)
+	tests := []struct {
+		name      string
+		query     string
+		mode      string
+		wantCode  string
+		wantExtra int
+	}{
+		{"un registro", "escenario=exito", multipleMatchesFlag, "", 0},
+		{"ignorar", "escenario=multiples", multipleMatchesIgnore, "", 0},
+		{"marcar", "escenario=multiples", multipleMatchesFlag, errCodeMultiple, 0},
+		{"extraer", "escenario=multiples", multipleMatchesExtract, "", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := browserTestConfig()
+			config.MultipleMatches = tt.mode
+			s := newBrowserScraper(t, config, srv, tt.query)
+
+			result := s.processCedula("1012345678", ctx, 1)
+			if result.Estado != "REGISTRO ACTIVO" || result.ErrorCode != tt.wantCode {
+				t.Errorf("Estado %q, ErrorCode %q (%s); se esperaba REGISTRO ACTIVO, %q",
+					result.Estado, result.ErrorCode, result.Error, tt.wantCode)
+			}
+			if len(result.Extra) != tt.wantExtra {
+				t.Fatalf("%d registros en Extra, se esperaban %d", len(result.Extra), tt.wantExtra)
+			}
+			estados := make(map[string]int)
+			for _, match := range result.Extra {
+				estados[match["estado"]]++
+			}
+			if tt.wantExtra > 0 && estados["REGISTRO CANCELADO"] != 1 {
+				t.Errorf("registros = %v, se esperaba uno con estado REGISTRO CANCELADO", result.Extra)
+			}
+		})
+	}
+}
+
+func TestProcessCedulaNetworkError(t *testing.T) {
+	ctx := newTestBrowser(t)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	s := newBrowserScraper(t, browserTestConfig(), closed, "")
+	result := s.processCedula("1012345678", ctx, 1)
+	if result.Estado != "Error" || result.ErrorCode != errCodeNetwork {
+		t.Errorf("Estado %q, ErrorCode %q (%s); se esperaba Error, %q",
+			result.Estado, result.ErrorCode, result.Error, errCodeNetwork)
+	}
+}
+
+func TestContinueOnPanic(t *testing.T) {
+	config := testConfig()
+	config.Concurrency = 1
+	config.ContinueOnPanic = true
+	launches := 0
+	s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+		if cedula == "1001" {
+			panic("falla dentro de chromedp")
+		}
+		return okResult(cedula, attempt)
+	})
+	s.launch = func(context.Context) error {
+		launches++
+		return nil
+	}
+
+	results := s.ProcessCedulas(testCedulas(3))
+	for _, result := range results {
+		want := "REGISTRO ACTIVO"
+		if result.Cedula == "1001" {
+			want = "Error"
+			if result.ErrorCode != errCodePanic || !strings.Contains(result.Error, "falla dentro de chromedp") {
+				t.Errorf("cédula 1001: ErrorCode %q, Error %q", result.ErrorCode, result.Error)
+			}
+		}
+		if result.Estado != want {
+			t.Errorf("cédula %s: Estado = %q, se esperaba %q", result.Cedula, result.Estado, want)
+		}
+	}
+	// El navegador se reinicia tras el panic
+	if launches != 2 {
+		t.Errorf("%d navegadores iniciados, se esperaban 2", launches)
+	}
+}
+
+func TestProcessInputStream(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int
+	}{
+		{"sin lotes", 0},
+		{"por lotes", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.BatchSize = tt.batchSize
+			queried := make(chan string, 10)
+			s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+				queried <- cedula
+				return okResult(cedula, attempt)
+			})
+
+			in := make(chan InputRecord)
+			done := make(chan []Result)
+			go func() { done <- s.ProcessInputStream(in) }()
+
+			// Las primeras cédulas se consultan sin esperar al resto de la entrada
+			in <- InputRecord{Cedula: "1000"}
+			in <- InputRecord{Cedula: "1001"}
+			for i := 0; i < 2; i++ {
+				select {
+				case <-queried:
+				case <-time.After(5 * time.Second):
+					t.Fatal("no se consultó la cédula antes de terminar de leer la entrada")
+				}
+			}
+			in <- InputRecord{Cedula: "1002"}
+			close(in)
+
+			results := <-done
+			var got []string
+			for _, result := range results {
+				got = append(got, result.Cedula)
+			}
+			if want := []string{"1000", "1001", "1002"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("cédulas en la salida = %v, se esperaba %v", got, want)
+			}
+			if n := countEstado(results, "REGISTRO ACTIVO"); n != 3 {
+				t.Errorf("%d cédulas exitosas, se esperaban 3", n)
+			}
+		})
+	}
+}
+
+// Con una entrada grande leída del Excel fila por fila, la primera consulta
+// ocurre antes de que termine la lectura
+func TestPipelineStartsBeforeReaderFinishes(t *testing.T) {
+	const rows = 2000
+	data := [][]interface{}{{"Cedula"}}
+	for i := 0; i < rows; i++ {
+		data = append(data, []interface{}{strconv.Itoa(10000 + i)})
+	}
+	path := writeExcelInput(t, data)
+
+	var firstQuery atomic.Int64
+	s := newTestScraper(t, testConfig(), func(cedula string, attempt int) Result {
+		firstQuery.CompareAndSwap(0, time.Now().UnixNano())
+		return okResult(cedula, attempt)
+	})
+
+	in := make(chan InputRecord)
+	var readDone int64
+	go func() {
+		defer close(in)
+		if err := streamInputs(path, "", nil, func(record InputRecord) { in <- record }); err != nil {
+			t.Error(err)
+		}
+		readDone = time.Now().UnixNano()
+	}()
+
+	results := s.ProcessInputStream(in)
+	if n := countEstado(results, "REGISTRO ACTIVO"); n != rows {
+		t.Fatalf("%d cédulas procesadas, se esperaban %d", n, rows)
+	}
+	if first := firstQuery.Load(); first == 0 || first >= readDone {
+		t.Errorf("la primera consulta (%d) no ocurrió antes de terminar la lectura (%d)", first, readDone)
+	}
+}
+
+// Si ningún navegador arranca, la entrada que falta por leer queda pendiente
+// en vez de bloquear la lectura
+func TestPipelineWithoutWorkers(t *testing.T) {
+	s := newTestScraper(t, testConfig(), okResult)
+	s.launch = func(context.Context) error { return errors.New("chrome no disponible") }
+
+	in := make(chan InputRecord)
+	go func() {
+		defer close(in)
+		for _, cedula := range testCedulas(20) {
+			in <- InputRecord{Cedula: cedula}
+		}
+	}()
+
+	done := make(chan []Result)
+	go func() { done <- s.ProcessInputStream(in) }()
+	select {
+	case results := <-done:
+		if len(results) != 20 {
+			t.Fatalf("%d resultados, se esperaban 20", len(results))
+		}
+		if n := countEstado(results, "Pendiente"); n != 20 {
+			t.Errorf("%d cédulas pendientes, se esperaban 20", n)
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("el procesamiento quedó bloqueado sin navegadores")
+	}
+}
+
+// Con RetryOnDifferentWorker el reintento queda en la cola compartida con lo
+// acumulado, marcado con el worker que falló
+func TestRetryHandoff(t *testing.T) {
+	captchaError := Result{Estado: "Error", Error: "Error con captcha: respuesta rechazada", Captchas: 1, CaptchaRequired: true}
+	tests := []struct {
+		name          string
+		enabled       bool
+		activeWorkers int32
+		job           retryJob
+		wantHandedOff bool
+		wantAttempts  int
+		wantCaptchas  int
+	}{
+		{"desactivado", false, 2, retryJob{Cedula: "1000", Attempt: 1}, false, 2, 2},
+		{"pasa a otro worker", true, 2, retryJob{Cedula: "1000", Attempt: 1}, true, 1, 1},
+		// Sin otro worker activo se reintenta en el mismo
+		{"único worker", true, 1, retryJob{Cedula: "1000", Attempt: 1}, false, 2, 2},
+		// El worker que toma el reintento sigue desde el intento y los captchas recibidos
+		{"reintento recibido", true, 2, retryJob{Cedula: "1000", Attempt: 2, Worker: 0, Captchas: 1, CaptchaRequired: true}, false, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.RetryOnDifferentWorker = tt.enabled
+			s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+				if attempt == 1 {
+					return captchaError
+				}
+				result := okResult(cedula, attempt)
+				result.Captchas = 1
+				result.CaptchaRequired = true
+				return result
+			})
+			s.activeWorkers.Store(tt.activeWorkers)
+			w := s.newWorkerState(3)
+
+			result, handedOff := s.queryCedula(tt.job, context.Background(), w)
+			if handedOff != tt.wantHandedOff {
+				t.Fatalf("handedOff = %v, se esperaba %v", handedOff, tt.wantHandedOff)
+			}
+			if result.Attempts != tt.wantAttempts || result.Captchas != tt.wantCaptchas || !result.CaptchaRequired {
+				t.Errorf("intento %d con %d captchas (requerido %v), se esperaba intento %d con %d",
+					result.Attempts, result.Captchas, result.CaptchaRequired, tt.wantAttempts, tt.wantCaptchas)
+			}
+			if !handedOff {
+				if n := s.retries.pending(); n != 0 {
+					t.Errorf("%d reintentos en la cola sin pasar la cédula", n)
+				}
+				return
+			}
+			job, _, _ := s.retries.take(0, true)
+			want := retryJob{Cedula: "1000", Attempt: 2, Worker: 3, Captchas: 1, CaptchaRequired: true}
+			if !reflect.DeepEqual(job, want) {
+				t.Errorf("reintento en la cola = %+v, se esperaba %+v", job, want)
+			}
+		})
+	}
+}
+
+// Un worker no toma sus propios reintentos mientras haya otros workers y
+// cédulas nuevas
+func TestNextJob(t *testing.T) {
+	tests := []struct {
+		name          string
+		retry         *retryJob
+		cedulas       []string
+		closed        bool
+		activeWorkers int32
+		want          retryJob
+		wantOK        bool
+		wantQueued    int
+	}{
+		{
+			name:          "reintento de otro worker",
+			retry:         &retryJob{Cedula: "1000", Attempt: 2, Worker: 1},
+			activeWorkers: 2,
+			want:          retryJob{Cedula: "1000", Attempt: 2, Worker: 1},
+			wantOK:        true,
+		},
+		{
+			name:          "reintento propio con cédulas nuevas",
+			retry:         &retryJob{Cedula: "1000", Attempt: 2, Worker: 0},
+			cedulas:       []string{"2000"},
+			activeWorkers: 2,
+			want:          retryJob{Cedula: "2000", Attempt: 1},
+			wantOK:        true,
+			wantQueued:    1,
+		},
+		{
+			name:          "reintento propio siendo el único worker",
+			retry:         &retryJob{Cedula: "1000", Attempt: 2, Worker: 0},
+			activeWorkers: 1,
+			want:          retryJob{Cedula: "1000", Attempt: 2, Worker: 0},
+			wantOK:        true,
+		},
+		{
+			name:          "reintento propio sin cédulas nuevas",
+			retry:         &retryJob{Cedula: "1000", Attempt: 2, Worker: 0},
+			closed:        true,
+			activeWorkers: 2,
+			want:          retryJob{Cedula: "1000", Attempt: 2, Worker: 0},
+			wantOK:        true,
+		},
+		{name: "cédula nueva", cedulas: []string{"2000"}, activeWorkers: 2, want: retryJob{Cedula: "2000", Attempt: 1}, wantOK: true},
+		{name: "sin trabajo", closed: true, activeWorkers: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.RetryOnDifferentWorker = true
+			s := newTestScraper(t, config, okResult)
+			s.activeWorkers.Store(tt.activeWorkers)
+			if tt.retry != nil {
+				s.retries.push(*tt.retry)
+			}
+			jobs := make(chan string, len(tt.cedulas))
+			for _, cedula := range tt.cedulas {
+				jobs <- cedula
+			}
+			if tt.closed {
+				close(jobs)
+			}
+
+			job, ok := s.nextJob(jobs, 0)
+			if ok != tt.wantOK || !reflect.DeepEqual(job, tt.want) {
+				t.Errorf("nextJob = %+v, %v; se esperaba %+v, %v", job, ok, tt.want, tt.wantOK)
+			}
+			if n := s.retries.pending(); n != tt.wantQueued {
+				t.Errorf("%d reintentos en la cola, se esperaban %d", n, tt.wantQueued)
+			}
+		})
+	}
+}
+
+// Con su propio reintento en la cola y otros workers ocupados, el worker
+// espera sin volver a encolarlo hasta que llega otro trabajo
+func TestNextJobWaits(t *testing.T) {
+	own := retryJob{Cedula: "1000", Attempt: 2, Worker: 0}
+	tests := []struct {
+		name   string
+		wake   func(s *Scraper, jobs chan string)
+		want   retryJob
+		wantOK bool
+	}{
+		{
+			name:   "reintento de otro worker",
+			wake:   func(s *Scraper, _ chan string) { s.retries.push(retryJob{Cedula: "3000", Attempt: 2, Worker: 1}) },
+			want:   retryJob{Cedula: "3000", Attempt: 2, Worker: 1},
+			wantOK: true,
+		},
+		{
+			name:   "cédula nueva",
+			wake:   func(_ *Scraper, jobs chan string) { jobs <- "2000" },
+			want:   retryJob{Cedula: "2000", Attempt: 1},
+			wantOK: true,
+		},
+		{
+			name: "termina el otro worker",
+			wake: func(s *Scraper, _ chan string) {
+				s.activeWorkers.Add(-1)
+				s.retries.notify()
+			},
+			want:   own,
+			wantOK: true,
+		},
+		{
+			name:   "se acaban las cédulas",
+			wake:   func(_ *Scraper, jobs chan string) { close(jobs) },
+			want:   own,
+			wantOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.RetryOnDifferentWorker = true
+			s := newTestScraper(t, config, okResult)
+			s.activeWorkers.Store(2)
+			s.retries.push(own)
+			jobs := make(chan string)
+
+			type next struct {
+				job retryJob
+				ok  bool
+			}
+			done := make(chan next, 1)
+			go func() {
+				job, ok := s.nextJob(jobs, 0)
+				done <- next{job, ok}
+			}()
+
+			select {
+			case got := <-done:
+				t.Fatalf("nextJob no esperó: %+v, %v", got.job, got.ok)
+			case <-time.After(300 * time.Millisecond):
+			}
+			if n := s.retries.pending(); n != 1 {
+				t.Fatalf("%d reintentos en la cola mientras espera, se esperaba 1", n)
+			}
+
+			tt.wake(s, jobs)
+			select {
+			case got := <-done:
+				if got.ok != tt.wantOK || !reflect.DeepEqual(got.job, tt.want) {
+					t.Errorf("nextJob = %+v, %v; se esperaba %+v, %v", got.job, got.ok, tt.want, tt.wantOK)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("nextJob no despertó")
+			}
+		})
+	}
+}
+
+func TestIsSinRUTMessage(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"El NIT 1012345678 no está inscrito en el RUT", true},
+		{"EL DOCUMENTO NO SE ENCUENTRA INSCRITO EN EL RUT", true},
+		{"El documento no registra inscripción en el\n  RUT", true},
+		{"El código de verificación no es válido", false},
+		{"Error interno del servicio", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isSinRUTMessage(tt.text); got != tt.want {
+			t.Errorf("isSinRUTMessage(%q) = %v, se esperaba %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+// Con menos cédulas que navegadores solo se abren los necesarios, tanto por
+// lotes como en modo continuo
+func TestBrowsersNeverExceedCedulas(t *testing.T) {
+	tests := []struct {
+		name         string
+		cedulas      int
+		browsers     int
+		wantLaunches int32
+	}{
+		{"sin cédulas", 0, 8, 0},
+		{"una cédula", 1, 8, 1},
+		{"menos cédulas que navegadores", 3, 8, 3},
+		{"más cédulas que navegadores", 3, 2, 2},
+	}
+	for _, tt := range tests {
+		for _, mode := range []string{"lotes", "continuo"} {
+			t.Run(tt.name+" "+mode, func(t *testing.T) {
+				s := newTestScraper(t, testConfig(), okResult)
+				var launches atomic.Int32
+				s.launch = func(context.Context) error {
+					launches.Add(1)
+					return nil
+				}
+
+				cedulas := testCedulas(tt.cedulas)
+				if mode == "lotes" {
+					s.runBatch(cedulas, tt.browsers)
+				} else {
+					in := make(chan InputRecord, len(cedulas))
+					for _, cedula := range cedulas {
+						in <- InputRecord{Cedula: cedula}
+					}
+					close(in)
+					s.runStream(in, func([]InputRecord) {}, tt.browsers)
+				}
+				if got := launches.Load(); got != tt.wantLaunches {
+					t.Errorf("%d navegadores abiertos, se esperaban %d", got, tt.wantLaunches)
+				}
+			})
+		}
+	}
+}
+
+// La advertencia de seguridad de Chrome termina en TLS_ERROR
+func TestProcessCedulaTLSInterstitial(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+	s := newBrowserScraper(t, browserTestConfig(), srv, "")
+	s.consultURL = srv.URL + "/tls_interstitial.html"
+
+	start := time.Now()
+	result := s.processCedula("1012345678", ctx, 1)
+	if result.Estado != "Error" || result.ErrorCode != errCodeTLS {
+		t.Errorf("Estado %q, ErrorCode %q (%s); se esperaba Error, %s", result.Estado, result.ErrorCode, result.Error, errCodeTLS)
+	}
+	// Sin recargar la advertencia como si fuera una página en blanco
+	if elapsed := time.Since(start); elapsed > 2*blankPageWait {
+		t.Errorf("la consulta tardó %v, se recargó la advertencia", elapsed)
+	}
+}
+
+// Con TLSErrorAction abort el primer TLS_ERROR detiene la ejecución; con
+// retry la cédula se reintenta como un error de red
+func TestTLSErrorAction(t *testing.T) {
+	tests := []struct {
+		name         string
+		action       string
+		wantAttempts int
+		wantStopped  bool
+	}{
+		{"reintentar", tlsActionRetry, 3, false},
+		{"detener", tlsActionAbort, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.TLSErrorAction = tt.action
+			config.TimeoutConfig.MaxRetries = 3
+			s := newTestScraper(t, config, func(string, int) Result {
+				return Result{Estado: "Error", Error: "Error de certificado TLS al navegar", ErrorCode: errCodeTLS}
+			})
+
+			results := s.ProcessCedulas([]string{"1000"})
+			if results[0].Attempts != tt.wantAttempts || results[0].ErrorCode != errCodeTLS {
+				t.Errorf("%d intentos, ErrorCode %q; se esperaban %d intentos con %s",
+					results[0].Attempts, results[0].ErrorCode, tt.wantAttempts, errCodeTLS)
+			}
+			if stopped := s.stopReason != ""; stopped != tt.wantStopped {
+				t.Errorf("ejecución detenida = %v (%q), se esperaba %v", stopped, s.stopReason, tt.wantStopped)
+			}
+		})
+	}
+}
+
+func TestIsSessionLimitMessage(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Ya tiene una sesión activa desde otro equipo", true},
+		{"No se permiten SESIONES SIMULTÁNEAS desde la misma dirección", true},
+		{"Sesión concurrente detectada", true},
+		{"La sesión expiró, ingrese de nuevo", false},
+		{"El NIT 123 no está inscrito en el RUT", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isSessionLimitMessage(tt.text); got != tt.want {
+			t.Errorf("isSessionLimitMessage(%q) = %v, se esperaba %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+// Tras un SESSION_LIMIT la cédula se reintenta y, con
+// SerializeOnSessionLimit, el reintento espera a que no haya otra consulta
+// abierta
+func TestSessionLimitRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		serialize   bool
+		wantBlocked bool
+	}{
+		{"serializado", true, true},
+		{"sin serializar", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.SerializeOnSessionLimit = tt.serialize
+			config.TimeoutConfig.MaxRetries = 2
+			s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+				if attempt == 1 {
+					return Result{Estado: "Error", Error: "Sesión concurrente rechazada", ErrorCode: errCodeSessionLimit}
+				}
+				return okResult(cedula, attempt)
+			})
+
+			// Otra consulta en curso en otra pestaña
+			s.sessionGate.RLock()
+			done := make(chan Result, 1)
+			go func() {
+				result, _ := s.queryCedula(retryJob{Cedula: "1000", Attempt: 1}, context.Background(), s.newWorkerState(0))
+				done <- result
+			}()
+
+			select {
+			case <-done:
+				if tt.wantBlocked {
+					t.Fatal("el reintento no esperó a la otra consulta")
+				}
+				s.sessionGate.RUnlock()
+				return
+			case <-time.After(300 * time.Millisecond):
+				if !tt.wantBlocked {
+					s.sessionGate.RUnlock()
+					t.Fatal("el reintento quedó esperando sin SerializeOnSessionLimit")
+				}
+			}
+			s.sessionGate.RUnlock()
+			select {
+			case result := <-done:
+				if result.Attempts != 2 || result.Estado != "REGISTRO ACTIVO" {
+					t.Errorf("intento %d, Estado %q; se esperaba el intento 2 exitoso", result.Attempts, result.Estado)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("el reintento no terminó al cerrarse la otra consulta")
+			}
+		})
+	}
+}
+
+// Con la primera señal la consulta en curso termina dentro del tiempo de
+// gracia y las demás quedan pendientes; al agotarse la gracia o con una
+// segunda señal la consulta se cancela
+func TestShutdownOnSignal(t *testing.T) {
+	tests := []struct {
+		name       string
+		grace      time.Duration
+		work       time.Duration // duración de la consulta en curso
+		signals    int
+		wantEstado string // estado de la cédula en curso
+		wantMax    time.Duration
+	}{
+		{name: "termina dentro de la gracia", grace: 5 * time.Second, work: 200 * time.Millisecond, signals: 1, wantEstado: "REGISTRO ACTIVO", wantMax: 3 * time.Second},
+		{name: "gracia agotada", grace: 50 * time.Millisecond, work: time.Minute, signals: 1, wantEstado: "Error", wantMax: 3 * time.Second},
+		{name: "segunda señal", grace: time.Minute, work: time.Minute, signals: 2, wantEstado: "Error", wantMax: 3 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Concurrency = 1
+			s := newTestScraper(t, config, okResult)
+			started := make(chan struct{})
+			var once sync.Once
+			s.query = func(cedula string, ctx context.Context, attempt int) Result {
+				once.Do(func() { close(started) })
+				select {
+				case <-time.After(tt.work):
+					return Result{Cedula: cedula, Estado: "REGISTRO ACTIVO", Attempts: attempt}
+				case <-ctx.Done():
+					return Result{Cedula: cedula, Estado: "Error", Error: ctx.Err().Error(), Attempts: attempt}
+				}
+			}
+
+			signals := make(chan os.Signal, 2)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				s.shutdownOnSignal(signals, tt.grace)
+			}()
+			t.Cleanup(func() {
+				signals <- syscall.SIGTERM
+				<-done
+			})
+			go func() {
+				<-started
+				for i := 0; i < tt.signals; i++ {
+					signals <- syscall.SIGTERM
+				}
+			}()
+
+			begin := time.Now()
+			results := s.ProcessCedulas(testCedulas(3))
+			if elapsed := time.Since(begin); elapsed > tt.wantMax {
+				t.Errorf("terminó en %v, se esperaba a lo sumo %v", elapsed, tt.wantMax)
+			}
+			if len(results) != 3 {
+				t.Fatalf("%d resultados, se esperaban 3", len(results))
+			}
+			for _, result := range results {
+				want := "Pendiente"
+				if result.Cedula == "1000" {
+					want = tt.wantEstado
+				}
+				if result.Estado != want {
+					t.Errorf("Estado de %s = %q (%s), se esperaba %q", result.Cedula, result.Estado, result.Error, want)
+				}
+			}
+			if !strings.Contains(s.StopReason(), "señal") {
+				t.Errorf("motivo = %q, se esperaba la señal recibida", s.StopReason())
+			}
+		})
+	}
+}
+
+func TestJitter(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     time.Duration
+		fraction float64
+		min, max time.Duration
+	}{
+		{"sin jitter", time.Second, 0, time.Second, time.Second},
+		{"fracción negativa", time.Second, -1, time.Second, time.Second},
+		{"sin pausa", 0, 0.5, 0, 0},
+		{"mitad", time.Second, 0.5, time.Second, 1500 * time.Millisecond},
+		{"doble", time.Second, 1, time.Second, 2 * time.Second},
+	}
+	config := testConfig()
+	config.Seed = 1
+	s := newTestScraper(t, config, nil)
+	w := s.newWorkerState(0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if d := w.jitter(tt.base, tt.fraction); d < tt.min || d > tt.max {
+					t.Fatalf("jitter = %v, fuera de [%v, %v]", d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestJitterPerWorker(t *testing.T) {
+	sequence := func(w *workerState) []time.Duration {
+		out := make([]time.Duration, 5)
+		for i := range out {
+			out[i] = w.jitter(time.Second, 1)
+		}
+		return out
+	}
+	config := testConfig()
+	config.Seed = 42
+	a := newTestScraper(t, config, nil)
+	b := newTestScraper(t, config, nil)
+
+	first, second := sequence(a.newWorkerState(0)), sequence(a.newWorkerState(1))
+	if reflect.DeepEqual(first, second) {
+		t.Errorf("dos workers con las mismas pausas: %v", first)
+	}
+	// La misma semilla repite las pausas de la ejecución
+	if again := sequence(b.newWorkerState(0)); !reflect.DeepEqual(first, again) {
+		t.Errorf("con la misma semilla: %v y %v", first, again)
+	}
+}
+
+// Solo la primera consulta de cada worker espera FirstQueryDelay (entre el
+// valor y el doble); las siguientes no
+func TestFirstQueryDelay(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		minFirst time.Duration
+		maxFirst time.Duration
+		maxTotal time.Duration
+	}{
+		{"sin pausa", 0, 0, 100 * time.Millisecond, 300 * time.Millisecond},
+		// Si esperara cada consulta, cada worker tardaría al menos 4*delay
+		{"con pausa", delay, delay, 2*delay + 100*time.Millisecond, 3*delay + 100*time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Concurrency = 2
+			config.MaxParallelBrowsers = 2
+			config.FirstQueryDelay = tt.delay
+
+			var mu sync.Mutex
+			var first time.Time
+			s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+				mu.Lock()
+				if first.IsZero() {
+					first = time.Now()
+				}
+				mu.Unlock()
+				return okResult(cedula, attempt)
+			})
+
+			start := time.Now()
+			results := s.ProcessCedulas(testCedulas(8))
+			total := time.Since(start)
+			if n := countEstado(results, "REGISTRO ACTIVO"); n != 8 {
+				t.Fatalf("%d cédulas procesadas, se esperaban 8", n)
+			}
+			if wait := first.Sub(start); wait < tt.minFirst || wait > tt.maxFirst {
+				t.Errorf("primera consulta a los %v, se esperaba entre %v y %v", wait, tt.minFirst, tt.maxFirst)
+			}
+			if total > tt.maxTotal {
+				t.Errorf("el procesamiento tardó %v, se esperaba como máximo %v", total, tt.maxTotal)
+			}
+		})
+	}
+}
+
+func TestReloadIfBlank(t *testing.T) {
+	ctx := newTestBrowser(t)
+
+	// Cada página queda en blanco las primeras "blancas" veces que se pide
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path
+		mu.Lock()
+		requests[name]++
+		n := requests[name]
+		mu.Unlock()
+		blank, _ := strconv.Atoi(r.URL.Query().Get("blancas"))
+		if n <= blank {
+			io.WriteString(w, "<html><body></body></html>")
+			return
+		}
+		io.WriteString(w, `<html><body><input id="vistaConsultaEstadoRUT:formConsultaEstadoRUT:numNit"></body></html>`)
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name         string
+		page         string
+		blank        int
+		reloads      int
+		wantErr      bool
+		wantRequests int
+	}{
+		{"carga normal", "normal", 0, 2, false, 1},
+		{"se recupera al recargar", "recupera", 1, 2, false, 2},
+		{"sin recargas", "sinrecargas", 1, 0, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := testConfig()
+			config.PageReloads = tt.reloads
+			s := newTestScraper(t, config, nil)
+
+			tabCtx, cancel := chromedp.NewContext(ctx)
+			defer cancel()
+			path := "/" + tt.page
+			err := chromedp.Run(tabCtx,
+				chromedp.Navigate(fmt.Sprintf("%s%s?blancas=%d", srv.URL, path, tt.blank)),
+				s.reloadIfBlank(),
+			)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("reloadIfBlank: error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			mu.Lock()
+			got := requests[path]
+			mu.Unlock()
+			if got != tt.wantRequests {
+				t.Errorf("%d peticiones, se esperaban %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestApplyCaptchaConcurrency(t *testing.T) {
+	tests := []struct {
+		name         string
+		limit        int
+		capPages     bool
+		concurrency  int
+		browsers     int
+		wantConc     int
+		wantBrowsers int
+	}{
+		{"sin límite", 0, true, 8, 4, 8, 4},
+		{"límite sin CapPagesToCaptcha", 2, false, 8, 4, 8, 4},
+		{"limita consultas y navegadores", 2, true, 8, 4, 2, 2},
+		{"solo limita las consultas", 3, true, 8, 2, 3, 2},
+		{"un límite mayor no aumenta", 16, true, 8, 4, 8, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.TwoCaptchaMaxConcurrency = tt.limit
+			config.CapPagesToCaptcha = tt.capPages
+			config.Concurrency = tt.concurrency
+			config.MaxParallelBrowsers = tt.browsers
+
+			got := applyCaptchaConcurrency(config)
+			if got.Concurrency != tt.wantConc || got.MaxParallelBrowsers != tt.wantBrowsers {
+				t.Errorf("Concurrency %d, MaxParallelBrowsers %d; se esperaban %d y %d",
+					got.Concurrency, got.MaxParallelBrowsers, tt.wantConc, tt.wantBrowsers)
+			}
+
+			s := newTestScraper(t, config, nil)
+			if (s.captchaSem != nil) != (tt.limit > 0) {
+				t.Errorf("semáforo de captchas: %v, se esperaba con límite %d", s.captchaSem != nil, tt.limit)
+			}
+		})
+	}
+}
+
+func TestResultsChannel(t *testing.T) {
+	tests := []struct {
+		name  string
+		n     int
+		query func(string, int) Result
+	}{
+		{"todas exitosas", 6, okResult},
+		{"con errores", 4, errorResult},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.TimeoutConfig.MaxRetries = 1
+			s := newTestScraper(t, config, tt.query)
+
+			live := s.Results()
+			done := make(chan []Result)
+			go func() { done <- s.ProcessCedulas(testCedulas(tt.n)) }()
+
+			seen := make(map[string]int)
+			for result := range live {
+				seen[result.Cedula]++
+			}
+			results := <-done
+			if len(results) != tt.n {
+				t.Errorf("%d resultados en lote, se esperaban %d", len(results), tt.n)
+			}
+			for _, cedula := range testCedulas(tt.n) {
+				if seen[cedula] != 1 {
+					t.Errorf("cédula %s recibida %d veces por el canal", cedula, seen[cedula])
+				}
+			}
+			if len(seen) != tt.n {
+				t.Errorf("%d cédulas en el canal, se esperaban %d", len(seen), tt.n)
+			}
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*Config)
+		check     func(Config) bool
+	}{
+		{"concurrencia cero", func(c *Config) { c.Concurrency = 0 }, func(c Config) bool { return c.Concurrency == 1 }},
+		{"concurrencia negativa", func(c *Config) { c.Concurrency = -4 }, func(c Config) bool { return c.Concurrency == 1 }},
+		{"sin reintentos", func(c *Config) { c.TimeoutConfig.MaxRetries = 0 }, func(c Config) bool { return c.TimeoutConfig.MaxRetries == 1 }},
+		{"contadores negativos", func(c *Config) { c.BatchSize = -1; c.PageReloads = -2; c.MaxTotalTabs = -3 }, func(c Config) bool {
+			return c.BatchSize == 0 && c.PageReloads == 0 && c.MaxTotalTabs == 0
+		}},
+		{"valores válidos no cambian", func(c *Config) { c.Concurrency = 7; c.BatchSize = 50 }, func(c Config) bool {
+			return c.Concurrency == 7 && c.BatchSize == 50
+		}},
+		{"acción TLS desconocida", func(c *Config) { c.TLSErrorAction = "ignorar" }, func(c Config) bool {
+			return c.TLSErrorAction == tlsActionRetry
+		}},
+		{"método de envío desconocido", func(c *Config) { c.CaptchaSubmitMethod = "ftp" }, func(c Config) bool {
+			return c.CaptchaSubmitMethod == captchaSubmitBase64
+		}},
+		{"método de envío vacío", func(c *Config) { c.CaptchaSubmitMethod = "" }, func(c Config) bool {
+			return c.CaptchaSubmitMethod == captchaSubmitBase64
+		}},
+		{"envío multipart", func(c *Config) { c.CaptchaSubmitMethod = captchaSubmitMultipart }, func(c Config) bool {
+			return c.CaptchaSubmitMethod == captchaSubmitMultipart
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			tt.configure(&config)
+			if got := validateConfig(config); !tt.check(got) {
+				t.Errorf("validateConfig no corrigió la configuración: %+v", got)
+			}
+		})
+	}
+}
+
+// Con concurrencia cero el semáforo no dejaría pasar ninguna consulta
+func TestZeroConcurrencyStillProcesses(t *testing.T) {
+	for _, concurrency := range []int{0, -1} {
+		t.Run(strconv.Itoa(concurrency), func(t *testing.T) {
+			config := testConfig()
+			config.Concurrency = concurrency
+			config.MaxParallelBrowsers = concurrency
+			s := newTestScraper(t, config, okResult)
+
+			done := make(chan []Result)
+			go func() { done <- s.ProcessCedulas(testCedulas(3)) }()
+			select {
+			case results := <-done:
+				if n := countEstado(results, "REGISTRO ACTIVO"); n != 3 {
+					t.Errorf("%d cédulas procesadas, se esperaban 3", n)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("el procesamiento no terminó")
+			}
+		})
+	}
+}
+
+func TestReserveCaptchaSpend(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       float64
+		charged     float64 // gasto previo
+		solves      int
+		wantAllowed int
+		wantHalt    bool
+	}{
+		{"sin límite", 0, 0, 10, 10, false},
+		{"bajo el límite", 1, 0, 5, 5, false},
+		{"justo en el límite", 0.5, 0, 6, 5, true},
+		{"se detiene antes de superarlo", 0.35, 0, 5, 3, true},
+		{"cuenta el gasto previo", 0.35, 0.2, 5, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MaxCaptchaSpend = tt.limit
+			s := newTestScraper(t, config, nil)
+			s.captchaSpend = tt.charged
+
+			allowed := 0
+			for i := 0; i < tt.solves; i++ {
+				err := s.reserveCaptchaSpend(0.1)
+				if err == nil {
+					allowed++
+				} else if !errors.Is(err, errCaptchaBudget) {
+					t.Fatalf("reserveCaptchaSpend: %v", err)
+				}
+			}
+			if allowed != tt.wantAllowed {
+				t.Errorf("%d captchas permitidos, se esperaban %d", allowed, tt.wantAllowed)
+			}
+			if want := tt.charged + float64(tt.wantAllowed)*0.1; math.Abs(s.CaptchaSpend()-want) > 1e-9 {
+				t.Errorf("gasto $%.4f, se esperaba $%.4f", s.CaptchaSpend(), want)
+			}
+			if s.stopped() != tt.wantHalt {
+				t.Errorf("detenido: %v, se esperaba %v", s.stopped(), tt.wantHalt)
+			}
+		})
+	}
+}
+
+// Un envío que 2captcha rechaza no cuenta contra el presupuesto
+func TestCaptchaSpendRefundedOnSubmitError(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+	}{
+		{"sin saldo", `{"status":0,"request":"ERROR_ZERO_BALANCE"}`},
+		{"clave incorrecta", `{"status":0,"request":"ERROR_WRONG_USER_KEY"}`},
+		{"respuesta inválida", `no es json`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MaxCaptchaSpend = 1
+			config.CaptchaCost = 0.1
+			s := newTestScraper(t, config, nil)
+			_, client := newFakeTwoCaptcha(t, func(url.Values) string { return tt.response })
+			s.captcha = client
+
+			if _, _, err := s.solveCaptcha(pngImage(t, 120, 40)); err == nil {
+				t.Error("solveCaptcha no devolvió error")
+			}
+			if _, _, err := s.solveAudioCaptcha([]byte("audio"), 0.1); err == nil {
+				t.Error("solveAudioCaptcha no devolvió error")
+			}
+			if s.CaptchaSpend() != 0 {
+				t.Errorf("gasto $%.4f, se esperaba $0", s.CaptchaSpend())
+			}
+		})
+	}
+}
+
+// Al agotarse el presupuesto las cédulas restantes quedan pendientes
+func TestCaptchaSpendHaltsRun(t *testing.T) {
+	config := testConfig()
+	config.Concurrency = 1
+	config.MaxCaptchaSpend = 0.25
+	var s *Scraper
+	s = newTestScraper(t, config, func(cedula string, attempt int) Result {
+		if err := s.reserveCaptchaSpend(0.1); err != nil {
+			return Result{Estado: "Error", Error: err.Error()}
+		}
+		return okResult(cedula, attempt)
+	})
+
+	results := s.ProcessCedulas(testCedulas(8))
+	if len(results) != 8 {
+		t.Fatalf("%d resultados, se esperaban 8", len(results))
+	}
+	if n := countEstado(results, "REGISTRO ACTIVO"); n != 2 {
+		t.Errorf("%d cédulas consultadas, se esperaban 2", n)
+	}
+	if countEstado(results, "Pendiente") == 0 {
+		t.Error("no quedaron cédulas pendientes tras agotar el presupuesto")
+	}
+	if !s.stopped() {
+		t.Error("el procesamiento no se detuvo")
+	}
+	if s.CaptchaSpend() > config.MaxCaptchaSpend {
+		t.Errorf("gasto $%.4f superó el límite de $%.4f", s.CaptchaSpend(), config.MaxCaptchaSpend)
+	}
+}
+
+// Un resultado cuya cédula no coincide con la entrada (normalización
+// distinta) se agrega al final en vez de perderse
+func TestOrphanResults(t *testing.T) {
+	tests := []struct {
+		name        string
+		rename      map[string]string // cédula consultada -> cédula del resultado
+		wantOrphans []string
+	}{
+		{"sin huérfanos", nil, nil},
+		{"una cédula cambiada", map[string]string{"1001": "1.001"}, []string{"1.001"}},
+		{"varias cédulas cambiadas", map[string]string{"1000": "01000", "1002": " 1002"}, []string{"01000", " 1002"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Concurrency = 1
+			config.MaxParallelBrowsers = 1
+			s := newTestScraper(t, config, nil)
+			s.query = func(cedula string, _ context.Context, attempt int) Result {
+				result := okResult(cedula, attempt)
+				result.Cedula = cedula
+				if renamed, ok := tt.rename[cedula]; ok {
+					result.Cedula = renamed
+				}
+				return result
+			}
+
+			cedulas := testCedulas(4)
+			results := s.ProcessCedulas(cedulas)
+			if len(results) != len(cedulas)+len(tt.wantOrphans) {
+				t.Fatalf("%d resultados, se esperaban %d", len(results), len(cedulas)+len(tt.wantOrphans))
+			}
+			for i, cedula := range cedulas {
+				if _, renamed := tt.rename[cedula]; !renamed && results[i].Cedula != cedula {
+					t.Errorf("resultado %d: cédula %q, se esperaba %q", i, results[i].Cedula, cedula)
+				}
+			}
+			var orphans []string
+			for _, result := range results[len(cedulas):] {
+				if result.Estado != "REGISTRO ACTIVO" {
+					t.Errorf("huérfano %q con Estado %q", result.Cedula, result.Estado)
+				}
+				orphans = append(orphans, result.Cedula)
+			}
+			sort.Strings(orphans)
+			want := append([]string(nil), tt.wantOrphans...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(orphans, want) {
+				t.Errorf("huérfanos = %q, se esperaba %q", orphans, want)
+			}
+		})
+	}
+}
+
+func TestSilentBlockRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		blocked      int // consultas seguidas sin mensaje ni datos
+		maxRetries   int
+		wantCode     string
+		wantAttempts int
+	}{
+		{"se recupera", 1, 3, "", 2},
+		{"agota los reintentos", 5, 3, errCodeSilentBlock, 3},
+		{"sin reintentos", 1, 1, errCodeSilentBlock, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.TimeoutConfig.MaxRetries = tt.maxRetries
+			s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+				if attempt <= tt.blocked {
+					return Result{Estado: "Error", Error: "DIAN no devolvió datos ni mensaje (posible bloqueo)", ErrorCode: errCodeSilentBlock}
+				}
+				return okResult(cedula, attempt)
+			})
+
+			results := s.ProcessCedulas([]string{"1012345678"})
+			if len(results) != 1 {
+				t.Fatalf("%d resultados, se esperaba 1", len(results))
+			}
+			if got := results[0]; got.ErrorCode != tt.wantCode || got.Attempts != tt.wantAttempts {
+				t.Errorf("ErrorCode %q en %d intentos, se esperaba %q en %d", got.ErrorCode, got.Attempts, tt.wantCode, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRateLimitedRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		limited      int // consultas seguidas con la página de demasiados intentos
+		maxRetries   int
+		wantEstado   string
+		wantAttempts int
+	}{
+		{"se recupera", 1, 3, "REGISTRO ACTIVO", 2},
+		{"agota los reintentos", 5, 3, "RateLimited", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.RateLimitCooldown = time.Millisecond
+			config.TimeoutConfig.MaxRetries = tt.maxRetries
+			s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+				if attempt <= tt.limited {
+					return rateLimitedResult(Result{Cedula: cedula}, time.Now())
+				}
+				return okResult(cedula, attempt)
+			})
+
+			results := s.ProcessCedulas([]string{"1012345678"})
+			if results[0].Estado != tt.wantEstado || results[0].Attempts != tt.wantAttempts {
+				t.Errorf("Estado %q en el intento %d; se esperaba %q en el %d",
+					results[0].Estado, results[0].Attempts, tt.wantEstado, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Estados de la ejecución en el manifiesto
+const (
+	manifestRunning   = "running"
+	manifestCompleted = "completed"
+	manifestStopped   = "stopped" // detenida antes de terminar (señal, límites)
+)
+
+// Manifiesto de la ejecución para orquestadores: se escribe al empezar y se
+// actualiza al terminar con el estado y los contadores
+type RunManifest struct {
+	Version     string     `json:"version"`
+	Status      string     `json:"status"`
+	StartedAt   time.Time  `json:"startedAt"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	Input       string     `json:"input"`
+	StreamInput bool       `json:"streamInput,omitempty"`
+	// Cédulas a procesar; con -stream-input solo se conoce al terminar
+	TotalCedulas int    `json:"totalCedulas"`
+	Output       string `json:"output"`
+	Format       string `json:"format"`
+	LogFile      string `json:"logFile,omitempty"`
+	RunDir       string `json:"runDir,omitempty"`
+
+	Config manifestConfig `json:"config"`
+
+	// Solo al terminar
+	Stats      *RunStats `json:"stats,omitempty"`
+	Pending    int       `json:"pending,omitempty"`
+	StopReason string    `json:"stopReason,omitempty"`
+	Duration   string    `json:"duration,omitempty"`
+}
+
+// Parte de Config que describe la ejecución; sin la clave de 2captcha ni
+// las credenciales de los proxies
+type manifestConfig struct {
+	Concurrency         int      `json:"concurrency"`
+	MaxParallelBrowsers int      `json:"maxParallelBrowsers"`
+	BatchSize           int      `json:"batchSize"`
+	MaxRetries          int      `json:"maxRetries"`
+	Proxies             []string `json:"proxies,omitempty"`
+	CaptchaMethods      []string `json:"captchaMethods,omitempty"`
+	MaxCaptchaSpend     float64  `json:"maxCaptchaSpend,omitempty"`
+	SuccessStates       []string `json:"successStates,omitempty"`
+	Stealth             bool     `json:"stealth,omitempty"`
+}
+
+func newManifestConfig(config Config) manifestConfig {
+	mc := manifestConfig{
+		Concurrency:         config.Concurrency,
+		MaxParallelBrowsers: config.MaxParallelBrowsers,
+		BatchSize:           config.BatchSize,
+		MaxRetries:          config.TimeoutConfig.MaxRetries,
+		CaptchaMethods:      config.CaptchaMethods,
+		MaxCaptchaSpend:     config.MaxCaptchaSpend,
+		SuccessStates:       config.SuccessStates,
+		Stealth:             config.Stealth,
+	}
+	for _, proxy := range config.ProxyList {
+		mc.Proxies = append(mc.Proxies, proxyLabel(proxy))
+	}
+	return mc
+}
+
+// Completar el manifiesto con el resultado de la ejecución
+func (m *RunManifest) finish(results []Result, stats RunStats, stopReason string, finished time.Time) {
+	m.FinishedAt = &finished
+	m.Duration = finished.Sub(m.StartedAt).String()
+	m.Stats = &stats
+	m.StopReason = stopReason
+	m.Status = manifestCompleted
+	if stopReason != "" {
+		m.Status = manifestStopped
+	}
+	if m.TotalCedulas == 0 {
+		m.TotalCedulas = len(results)
+	}
+	m.Pending = 0
+	for _, result := range results {
+		if result.Estado == "Pendiente" {
+			m.Pending++
+		}
+	}
+}
+
+// Escribir el manifiesto de forma atómica (archivo temporal y renombrado),
+// para que un orquestador nunca lea un JSON a medias
+func writeManifest(path string, m RunManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error generando manifiesto: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".manifest-*.json")
+	if err != nil {
+		return fmt.Errorf("error creando manifiesto: %v", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error escribiendo manifiesto: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error escribiendo manifiesto: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error guardando manifiesto %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// El manifiesto no expone la clave de 2captcha ni las credenciales de los
+// proxies
+func TestNewManifestConfig(t *testing.T) {
+	config := testConfig()
+	config.APIKey = "clave-secreta"
+	config.ProxyList = []string{"http://usuario:clave@p1:3128", "http://p2:3128"}
+	config.TimeoutConfig.MaxRetries = 3
+	config.SuccessStates = []string{"REGISTRO ACTIVO"}
+
+	mc := newManifestConfig(config)
+	if want := []string{"http://p1:3128", "http://p2:3128"}; !reflect.DeepEqual(mc.Proxies, want) {
+		t.Errorf("proxies = %q, se esperaba %q", mc.Proxies, want)
+	}
+	if mc.Concurrency != config.Concurrency || mc.MaxRetries != 3 {
+		t.Errorf("manifiesto = %+v", mc)
+	}
+	data, err := json.Marshal(mc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"clave-secreta", "usuario:clave"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("el manifiesto contiene %q: %s", secret, data)
+		}
+	}
+}
+
+func TestRunManifestFinish(t *testing.T) {
+	started := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	finished := started.Add(90 * time.Second)
+	results := []Result{
+		{Cedula: "1", Estado: "REGISTRO ACTIVO"},
+		{Cedula: "2", Estado: "Pendiente"},
+		{Cedula: "3", Estado: "Pendiente"},
+	}
+	tests := []struct {
+		name        string
+		total       int
+		results     []Result
+		stopReason  string
+		wantStatus  string
+		wantTotal   int
+		wantPending int
+	}{
+		{"completada", 1, results[:1], "", manifestCompleted, 1, 0},
+		{"detenida", 3, results, "señal terminated recibida", manifestStopped, 3, 2},
+		// Con -stream-input el total se conoce al terminar
+		{"entrada en streaming", 0, results[:1], "", manifestCompleted, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := RunManifest{Status: manifestRunning, StartedAt: started, TotalCedulas: tt.total}
+			stats := RunStats{Processed: int64(len(tt.results))}
+			m.finish(tt.results, stats, tt.stopReason, finished)
+
+			if m.Status != tt.wantStatus {
+				t.Errorf("Status = %q, se esperaba %q", m.Status, tt.wantStatus)
+			}
+			if m.TotalCedulas != tt.wantTotal || m.Pending != tt.wantPending {
+				t.Errorf("total %d y %d pendientes, se esperaba %d y %d", m.TotalCedulas, m.Pending, tt.wantTotal, tt.wantPending)
+			}
+			if m.StopReason != tt.stopReason {
+				t.Errorf("StopReason = %q, se esperaba %q", m.StopReason, tt.stopReason)
+			}
+			if m.FinishedAt == nil || !m.FinishedAt.Equal(finished) || m.Duration != "1m30s" {
+				t.Errorf("fin %v y duración %q", m.FinishedAt, m.Duration)
+			}
+			if m.Stats == nil || *m.Stats != stats {
+				t.Errorf("Stats = %+v, se esperaba %+v", m.Stats, stats)
+			}
+		})
+	}
+}
+
+// El manifiesto se reescribe completo y no deja temporales
+func TestWriteManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.json")
+	started := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	m := RunManifest{Version: "test", Status: manifestRunning, StartedAt: started, Input: "cedulas.xlsx", Output: "resultados.xlsx", Format: "xlsx"}
+	for _, tt := range []struct {
+		name string
+		step func()
+		want string
+	}{
+		{"al empezar", func() {}, manifestRunning},
+		{"al terminar", func() {
+			m.finish([]Result{{Cedula: "1", Estado: "REGISTRO ACTIVO"}}, RunStats{Processed: 1, Successful: 1}, "", started.Add(time.Minute))
+		}, manifestCompleted},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.step()
+			if err := writeManifest(path, m); err != nil {
+				t.Fatalf("writeManifest: %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got RunManifest
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("manifiesto inválido: %v\n%s", err, data)
+			}
+			if got.Status != tt.want || got.Input != m.Input || !got.StartedAt.Equal(started) {
+				t.Errorf("manifiesto = %+v, se esperaba estado %q", got, tt.want)
+			}
+			if tt.want == manifestRunning && (got.Stats != nil || got.FinishedAt != nil) {
+				t.Errorf("el manifiesto inicial tiene datos del final: %s", data)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("archivos en el directorio: %v, se esperaba solo el manifiesto", entries)
+			}
+		})
+	}
+
+	if err := writeManifest(filepath.Join(dir, "no-existe", "manifest.json"), m); err == nil {
+		t.Error("se esperaba error con un directorio inexistente")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Qué hacer cuando una consulta devuelve más de un registro
+const (
+	multipleMatchesIgnore  = ""        // quedarse con el registro que se muestre primero
+	multipleMatchesFlag    = "flag"    // marcar el resultado con MULTIPLE_MATCHES
+	multipleMatchesExtract = "extract" // guardar todos los registros en Result.Extra
+)
+
+// Sufijos de id de los campos de cada registro y el nombre con el que se
+// guardan en Result.Extra
+var matchFields = []struct {
+	suffix string
+	name   string
+}{
+	{"numNit", "cedula"},
+	{"primerApellido", "primerApellido"},
+	{"segundoApellido", "segundoApellido"},
+	{"primerNombre", "primerNombre"},
+	{"otrosNombres", "segundoNombre"},
+	{"estado", "estado"},
+}
+
+// Leer todos los registros de la respuesta. JSF repite los campos con ids
+// que terminan igual (form:tabla:0:estado, form:tabla:1:estado...), así que
+// el i-ésimo elemento de cada campo forma el registro i. El número de
+// registros lo da el campo estado. Los inputs del formulario (numNit) se omiten
+func extractMatches(ctx context.Context) ([]map[string]string, error) {
+	suffixes := make([]string, len(matchFields))
+	for i, field := range matchFields {
+		suffixes[i] = field.suffix
+	}
+	suffixesJSON, _ := json.Marshal(suffixes)
+	expr := fmt.Sprintf(`(() => {
+		const values = {};
+		for (const suffix of %s) {
+			values[suffix] = Array.from(document.querySelectorAll('[id$=":' + suffix + '"]'))
+				.filter(el => el.tagName !== 'INPUT')
+				.map(el => el.textContent.trim());
+		}
+		return values;
+	})()`, suffixesJSON)
+
+	var values map[string][]string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(expr, &values)); err != nil {
+		return nil, fmt.Errorf("error leyendo registros: %v", err)
+	}
+
+	rows := len(values["estado"])
+	matches := make([]map[string]string, rows)
+	for i := range matches {
+		match := make(map[string]string, len(matchFields))
+		for _, field := range matchFields {
+			if column := values[field.suffix]; i < len(column) {
+				match[field.name] = column[i]
+			}
+		}
+		matches[i] = match
+	}
+	return matches, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Revisar periódicamente el uso de memoria y pedir que se cierre un
+// navegador mientras se supere MaxMemory
+func (s *Scraper) monitorMemory(done <-chan struct{}) {
+	interval := s.config.MemoryPollInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		usage, err := s.memUsage()
+		if err != nil {
+			log.Printf("Error leyendo uso de memoria: %v", err)
+			continue
+		}
+		if usage <= s.config.MaxMemory {
+			continue
+		}
+
+		// Siempre queda al menos un navegador trabajando
+		if s.activeWorkers.Load() <= 1 {
+			log.Printf("Memoria en %d MB (límite %d MB), pero solo queda un navegador",
+				usage/1024/1024, s.config.MaxMemory/1024/1024)
+			continue
+		}
+		select {
+		case s.shed <- struct{}{}:
+			log.Printf("Memoria en %d MB (límite %d MB), se cerrará un navegador",
+				usage/1024/1024, s.config.MaxMemory/1024/1024)
+		default:
+			// Ya hay una solicitud pendiente
+		}
+	}
+}
+
+// Tomar la solicitud de cierre, si hay una
+func (s *Scraper) shouldShed() bool {
+	select {
+	case <-s.shed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Memoria residente del proceso y sus descendientes (los navegadores). Usa
+// /proc en Linux; en otros sistemas solo cuenta la memoria del proceso Go
+func processTreeRSS() (uint64, error) {
+	if _, err := os.Stat("/proc/self/statm"); err != nil {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return m.Sys, nil
+	}
+
+	// Mapa padre -> hijos a partir de /proc/<pid>/stat
+	children := make(map[int][]int)
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+	for _, path := range stats {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// El nombre del comando va entre paréntesis y puede contener espacios
+		line := string(data)
+		end := strings.LastIndexByte(line, ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(line[end+1:])
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(strings.Fields(line)[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		children[ppid] = append(children[ppid], pid)
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	var total uint64
+	pending := []int{os.Getpid()}
+	for len(pending) > 0 {
+		pid := pending[0]
+		pending = append(pending[1:], children[pid]...)
+
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+		if err != nil {
+			continue
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) < 2 {
+			continue
+		}
+		pages, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		total += pages * pageSize
+	}
+
+	return total, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMonitorMemory(t *testing.T) {
+	tests := []struct {
+		name     string
+		usage    uint64
+		err      error
+		active   int32
+		wantShed bool
+	}{
+		{name: "bajo el límite", usage: 50, active: 2, wantShed: false},
+		{name: "sobre el límite", usage: 200, active: 2, wantShed: true},
+		{name: "último navegador", usage: 200, active: 1, wantShed: false},
+		{name: "error de lectura", err: errors.New("sin /proc"), active: 2, wantShed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MaxMemory = 100
+			config.MemoryPollInterval = time.Millisecond
+			s := newTestScraper(t, config, nil)
+			s.memUsage = func() (uint64, error) { return tt.usage, tt.err }
+			s.activeWorkers.Store(tt.active)
+
+			done := make(chan struct{})
+			go s.monitorMemory(done)
+			time.Sleep(20 * time.Millisecond)
+			close(done)
+
+			if got := s.shouldShed(); got != tt.wantShed {
+				t.Errorf("shouldShed() = %v, se esperaba %v", got, tt.wantShed)
+			}
+			// La solicitud se toma una sola vez
+			if s.shouldShed() {
+				t.Error("shouldShed() devolvió true dos veces seguidas")
+			}
+		})
+	}
+}
+
+func TestMemoryShedding(t *testing.T) {
+	config := testConfig()
+	config.MaxParallelBrowsers = 3
+	config.Concurrency = 3
+	config.MaxMemory = 100
+	config.MemoryPollInterval = time.Millisecond
+	s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+		time.Sleep(5 * time.Millisecond)
+		return okResult(cedula, attempt)
+	})
+	s.memUsage = func() (uint64, error) { return 1000, nil }
+
+	cedulas := testCedulas(20)
+	results := s.ProcessCedulas(cedulas)
+	if len(results) != len(cedulas) {
+		t.Fatalf("%d resultados, se esperaban %d", len(results), len(cedulas))
+	}
+	if n := countEstado(results, "REGISTRO ACTIVO"); n != len(cedulas) {
+		t.Errorf("%d cédulas exitosas, se esperaban %d", n, len(cedulas))
+	}
+}
+
+func TestProcessTreeRSS(t *testing.T) {
+	usage, err := processTreeRSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage == 0 {
+		t.Error("processTreeRSS() = 0, se esperaba la memoria del proceso de prueba")
+	}
+}
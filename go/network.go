@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Peticiones en curso de una pestaña, a partir de los eventos de red. Se
+// registra al crear la pestaña para no perder las peticiones que dispara un clic
+type networkTracker struct {
+	mu       sync.Mutex
+	inflight map[network.RequestID]bool
+	last     time.Time // última vez que empezó o terminó una petición
+
+	// Momento del clic que se espera (mark) y si desde entonces empezó alguna
+	// petición: antes de eso la red inactiva es la de la página anterior
+	marked       time.Time
+	startedSince bool
+
+	// Código HTTP de la última respuesta del documento de la consulta
+	docStatus int
+}
+
+// docURL es la página de la consulta, cuya respuesta da documentStatus
+func newNetworkTracker(ctx context.Context, docURL string) *networkTracker {
+	t := &networkTracker{
+		inflight: make(map[network.RequestID]bool),
+		last:     time.Now(),
+	}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			t.update(ev.RequestID, true)
+		case *network.EventLoadingFinished:
+			t.update(ev.RequestID, false)
+		case *network.EventLoadingFailed:
+			t.update(ev.RequestID, false)
+		case *network.EventResponseReceived:
+			if ev.Type == network.ResourceTypeDocument && strings.HasPrefix(ev.Response.URL, docURL) {
+				t.mu.Lock()
+				t.docStatus = int(ev.Response.Status)
+				t.mu.Unlock()
+			}
+		}
+	})
+	return t
+}
+
+func (t *networkTracker) update(id network.RequestID, started bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if started {
+		t.inflight[id] = true
+		t.startedSince = true
+	} else {
+		delete(t.inflight, id)
+	}
+	t.last = time.Now()
+}
+
+// Código HTTP del documento de la consulta (0 si aún no hay respuesta)
+func (t *networkTracker) documentStatus() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.docStatus
+}
+
+// Marcar el momento justo antes de un clic: waitNetworkIdle no da la red
+// por inactiva hasta que empiece al menos una petición posterior
+func (t *networkTracker) mark() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.marked = time.Now()
+		t.startedSince = false
+		return nil
+	})
+}
+
+func (t *networkTracker) idleFor() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.inflight) > 0 {
+		return 0
+	}
+	// El ajax del clic todavía no empezó
+	if !t.marked.IsZero() && !t.startedSince {
+		return 0
+	}
+	return time.Since(t.last)
+}
+
+// Esperar a que no haya peticiones en curso durante quiet (el ajax de JSF
+// terminó), como máximo timeout. Tras mark se espera además a que empiece
+// alguna petición, para no leer la página anterior al clic. Al agotarse el
+// tiempo se sigue igual, como con la pausa fija
+func waitNetworkIdle(ctx context.Context, t *networkTracker, quiet, timeout time.Duration) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if t.idleFor() >= quiet {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			log.Printf("La red no quedó inactiva en %v; se continúa", timeout)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+func TestWaitNetworkIdle(t *testing.T) {
+	const (
+		quiet   = 200 * time.Millisecond
+		timeout = 2 * time.Second
+	)
+	tests := []struct {
+		name    string
+		mark    bool
+		start   time.Duration // inicio de la petición simulada (negativo: ninguna)
+		finish  time.Duration
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{"sin peticiones", false, -1, 0, quiet, timeout},
+		{"petición en curso", false, 0, 400 * time.Millisecond, 400*time.Millisecond + quiet, timeout},
+		{"clic con ajax", true, 200 * time.Millisecond, 500 * time.Millisecond, 500*time.Millisecond + quiet, timeout},
+		// Sin petición después del clic se espera hasta el límite
+		{"clic sin ajax", true, -1, 0, timeout, timeout + time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tracker := &networkTracker{inflight: make(map[network.RequestID]bool), last: time.Now()}
+			if tt.mark {
+				tracker.mark().Do(context.Background())
+			}
+			if tt.start >= 0 {
+				time.AfterFunc(tt.start, func() { tracker.update("1", true) })
+				time.AfterFunc(tt.finish, func() { tracker.update("1", false) })
+			}
+
+			begin := time.Now()
+			if err := waitNetworkIdle(context.Background(), tracker, quiet, timeout); err != nil {
+				t.Fatalf("waitNetworkIdle: %v", err)
+			}
+			if elapsed := time.Since(begin); elapsed < tt.wantMin || elapsed > tt.wantMax {
+				t.Errorf("esperó %v, se esperaba entre %v y %v", elapsed, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestWaitNetworkIdleCanceled(t *testing.T) {
+	tracker := &networkTracker{inflight: map[network.RequestID]bool{"1": true}, last: time.Now()}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := waitNetworkIdle(ctx, tracker, time.Millisecond, time.Second); err != context.Canceled {
+		t.Errorf("waitNetworkIdle = %v, se esperaba %v", err, context.Canceled)
+	}
+}
+
+// El clic dispara una petición lenta: la espera termina cuando la respuesta
+// llegó y la página ya la muestra
+func TestWaitNetworkIdleBrowser(t *testing.T) {
+	ctx := newTestBrowser(t)
+
+	const delay = 800 * time.Millisecond
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<html><body><button id="buscar">Buscar</button><span id="salida"></span>
+<script>
+document.getElementById("buscar").addEventListener("click", async () => {
+  const texto = await (await fetch("lento")).text();
+  document.getElementById("salida").textContent = texto;
+});
+</script></body></html>`)
+	})
+	mux.HandleFunc("/lento", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		io.WriteString(w, "listo")
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	tabCtx, cancel := chromedp.NewContext(ctx)
+	defer cancel()
+	tracker := newNetworkTracker(tabCtx, srv.URL)
+	if err := chromedp.Run(tabCtx, network.Enable(), chromedp.Navigate(srv.URL)); err != nil {
+		t.Fatal(err)
+	}
+
+	var begin time.Time
+	var text string
+	err := chromedp.Run(tabCtx,
+		tracker.mark(),
+		chromedp.ActionFunc(func(context.Context) error { begin = time.Now(); return nil }),
+		chromedp.Click("#buscar", chromedp.ByQuery),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			return waitNetworkIdle(ctx, tracker, 300*time.Millisecond, 5*time.Second)
+		}),
+		chromedp.Text("#salida", &text, chromedp.ByQuery),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(begin); elapsed < delay {
+		t.Errorf("esperó %v, menos que la respuesta (%v)", elapsed, delay)
+	}
+	if text != "listo" {
+		t.Errorf("texto = %q después de la espera, se esperaba %q", text, "listo")
+	}
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Destino que recibe cada resultado a medida que llega, sin esperar a que
+// termine todo el procesamiento
+type ResultSink interface {
+	Write(result Result) error
+	Close() error
+}
+
+// Escribe un objeto JSON por línea (JSONL). El buffer se vacía cada
+// flushEvery resultados o cuando pasa flushInterval desde el último vaciado,
+// aunque no lleguen resultados (captchas lentos, pausas); con ambos en cero
+// se vacía tras cada resultado
+type jsonlSink struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+
+	flushEvery    int
+	flushInterval time.Duration
+	pending       int
+	lastFlush     time.Time
+
+	// Columnas a escribir, en orden (nil = el Result completo)
+	columns []ColumnSpec
+
+	// Vaciado periódico con flushInterval; stop lo detiene y done indica que terminó
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Crear un sink JSONL; "-" escribe en la salida estándar
+func newJSONLSink(filename string, flushEvery int, flushInterval time.Duration) (*jsonlSink, error) {
+	var file *os.File
+	if filename == "-" {
+		file = os.Stdout
+	} else {
+		f, err := os.Create(filename)
+		if err != nil {
+			return nil, fmt.Errorf("error creando archivo JSONL: %v", err)
+		}
+		file = f
+	}
+
+	w := bufio.NewWriter(file)
+	j := &jsonlSink{
+		file:          file,
+		w:             w,
+		enc:           json.NewEncoder(w),
+		flushEvery:    flushEvery,
+		flushInterval: flushInterval,
+		lastFlush:     time.Now(),
+	}
+	if flushInterval > 0 {
+		j.stop = make(chan struct{})
+		j.done = make(chan struct{})
+		go j.flushLoop()
+	}
+	return j, nil
+}
+
+func (j *jsonlSink) flushLoop() {
+	defer close(j.done)
+	ticker := time.NewTicker(j.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.stop:
+			return
+		case <-ticker.C:
+			j.mu.Lock()
+			if j.pending > 0 && time.Since(j.lastFlush) >= j.flushInterval {
+				if err := j.flush(); err != nil {
+					log.Printf("Error vaciando salida JSONL: %v", err)
+				}
+			}
+			j.mu.Unlock()
+		}
+	}
+}
+
+func (j *jsonlSink) Write(result Result) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.encode(result); err != nil {
+		return fmt.Errorf("error escribiendo resultado JSONL: %v", err)
+	}
+	j.pending++
+
+	if j.shouldFlush() {
+		return j.flush()
+	}
+	return nil
+}
+
+func (j *jsonlSink) encode(result Result) error {
+	if j.columns == nil {
+		return j.enc.Encode(result)
+	}
+	line, err := columnsJSON(result, j.columns)
+	if err != nil {
+		return err
+	}
+	_, err = j.w.Write(append(line, '\n'))
+	return err
+}
+
+func (j *jsonlSink) shouldFlush() bool {
+	if j.flushEvery <= 0 && j.flushInterval <= 0 {
+		return true
+	}
+	if j.flushEvery > 0 && j.pending >= j.flushEvery {
+		return true
+	}
+	return j.flushInterval > 0 && time.Since(j.lastFlush) >= j.flushInterval
+}
+
+func (j *jsonlSink) flush() error {
+	j.pending = 0
+	j.lastFlush = time.Now()
+	return j.w.Flush()
+}
+
+func (j *jsonlSink) Close() error {
+	if j.stop != nil {
+		close(j.stop)
+		<-j.done
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.flush(); err != nil {
+		return err
+	}
+	if j.file == os.Stdout {
+		return nil
+	}
+	return j.file.Close()
+}
+
+// Qué hacer cuando el buffer de asyncSink está lleno
+const (
+	sinkOverflowBlock = "block" // esperar a que el sink libere espacio
+	sinkOverflowSpill = "spill" // guardar en disco y escribir al cerrar
+)
+
+// Envuelve un sink lento (webhook, S3) para que escriba en su propia
+// goroutine y no detenga al recolector ni a los workers. Con overflow
+// "spill" los resultados que no caben en el buffer se guardan en un archivo
+// temporal y se escriben al cerrar, por lo que pueden salir en otro orden
+type asyncSink struct {
+	inner    ResultSink
+	ch       chan Result
+	overflow string
+	done     chan struct{}
+
+	spillMu  sync.Mutex
+	spill    *os.File
+	spillEnc *json.Encoder
+	spilled  int
+}
+
+func newAsyncSink(inner ResultSink, buffer int, overflow string) (*asyncSink, error) {
+	if buffer <= 0 {
+		buffer = 1
+	}
+	switch overflow {
+	case "":
+		overflow = sinkOverflowBlock
+	case sinkOverflowBlock, sinkOverflowSpill:
+	default:
+		return nil, fmt.Errorf("modo de desborde no soportado: %s", overflow)
+	}
+
+	a := &asyncSink{
+		inner:    inner,
+		ch:       make(chan Result, buffer),
+		overflow: overflow,
+		done:     make(chan struct{}),
+	}
+	go a.run()
+	return a, nil
+}
+
+func (a *asyncSink) run() {
+	defer close(a.done)
+	for result := range a.ch {
+		if err := a.inner.Write(result); err != nil {
+			log.Printf("Error escribiendo resultado de cédula %s: %v", result.Cedula, err)
+		}
+	}
+}
+
+func (a *asyncSink) Write(result Result) error {
+	if a.overflow == sinkOverflowBlock {
+		a.ch <- result
+		return nil
+	}
+	select {
+	case a.ch <- result:
+		return nil
+	default:
+		return a.spillResult(result)
+	}
+}
+
+func (a *asyncSink) spillResult(result Result) error {
+	a.spillMu.Lock()
+	defer a.spillMu.Unlock()
+	if a.spill == nil {
+		f, err := os.CreateTemp("", "dian-spill-*.jsonl")
+		if err != nil {
+			return fmt.Errorf("error creando archivo de desborde: %v", err)
+		}
+		a.spill = f
+		a.spillEnc = json.NewEncoder(f)
+		log.Printf("Buffer de salida lleno; desbordando a %s", f.Name())
+	}
+	if err := a.spillEnc.Encode(result); err != nil {
+		return fmt.Errorf("error escribiendo desborde: %v", err)
+	}
+	a.spilled++
+	return nil
+}
+
+// Vaciar el buffer, escribir lo desbordado y cerrar el sink interno
+func (a *asyncSink) Close() error {
+	close(a.ch)
+	<-a.done
+
+	if a.spill != nil {
+		if err := a.replaySpill(); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+	return a.inner.Close()
+}
+
+func (a *asyncSink) replaySpill() error {
+	name := a.spill.Name()
+	defer os.Remove(name)
+	defer a.spill.Close()
+
+	log.Printf("Escribiendo %d resultados desbordados", a.spilled)
+	if _, err := a.spill.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error leyendo desborde %s: %v", name, err)
+	}
+	dec := json.NewDecoder(a.spill)
+	for {
+		var result Result
+		if err := dec.Decode(&result); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error leyendo desborde %s: %v", name, err)
+		}
+		if err := a.inner.Write(result); err != nil {
+			log.Printf("Error escribiendo resultado de cédula %s: %v", result.Cedula, err)
+		}
+	}
+}
+
+func writeResultsToJSONL(filename string, results []Result, columns []ColumnSpec) error {
+	// Escritura completa: basta con vaciar el buffer al cerrar
+	sink, err := newJSONLSink(filename, len(results)+1, 0)
+	if err != nil {
+		return err
+	}
+	sink.columns = columns
+	for _, result := range results {
+		if err := sink.Write(result); err != nil {
+			sink.Close()
+			return err
+		}
+	}
+	return sink.Close()
+}
+
+// Deducir el formato de salida a partir de la extensión del archivo
+func outputFormat(filename, format string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+	if filename == "-" {
+		return "jsonl"
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	case ".parquet":
+		return "parquet"
+	default:
+		return "xlsx"
+	}
+}
+
+// Nombre del directorio de cada ejecución dentro de -output-dir
+const runDirLayout = "2006-01-02T15-04-05"
+
+// Crear base/<fecha y hora> para los archivos de una ejecución
+func createRunDir(base string, now time.Time) (string, error) {
+	dir := filepath.Join(base, now.Format(runDirLayout))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("error creando directorio %s: %v", dir, err)
+	}
+	return dir, nil
+}
+
+// Ubicar una ruta relativa dentro del directorio de la ejecución. Las rutas
+// absolutas y "-" (salida estándar) no cambian
+func runPath(runDir, path string) string {
+	if runDir == "" || path == "-" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(runDir, path)
+}
+
+// Qué hacer si el archivo de salida ya existe
+const (
+	ifExistsOverwrite = "overwrite" // reemplazarlo
+	ifExistsError     = "error"     // no escribir nada
+	ifExistsRename    = "rename"    // usar resultados_1.xlsx, resultados_2.xlsx...
+)
+
+// Ruta final de un archivo de salida según mode. "-" (salida estándar) no cambia
+func resolveOutputPath(filename, mode string) (string, error) {
+	if filename == "-" {
+		return filename, nil
+	}
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return filename, nil
+	}
+
+	switch mode {
+	case ifExistsOverwrite, "":
+		return filename, nil
+	case ifExistsError:
+		return "", fmt.Errorf("el archivo %s ya existe", filename)
+	case ifExistsRename:
+		ext := filepath.Ext(filename)
+		base := strings.TrimSuffix(filename, ext)
+		for i := 1; ; i++ {
+			candidate := fmt.Sprintf("%s_%d%s", base, i, ext)
+			if _, err := os.Stat(candidate); os.IsNotExist(err) {
+				log.Printf("El archivo %s ya existe; se usará %s", filename, candidate)
+				return candidate, nil
+			}
+		}
+	default:
+		return "", fmt.Errorf("opción de archivo existente no soportada: %s", mode)
+	}
+}
+
+// Hoja con el resumen de la ejecución en la salida Excel
+const summarySheetName = "Resumen"
+
+// Opciones de los escritores de resultados
+type OutputOptions struct {
+	// Agregar la hoja de resumen como primera hoja del Excel
+	SummarySheet bool
+	// Columnas de la salida, en orden (vacío = todas)
+	Columns []ColumnSpec
+	// Estados que cuentan como éxito en la hoja de resumen (vacío = cualquiera)
+	SuccessStates []string
+	// Contadores por worker para la hoja de resumen (opcional)
+	WorkerStats []WorkerStats
+	// Columnas de la entrada copiadas al final de cada fila (Result.Meta)
+	Passthrough []string
+}
+
+// Escribir los resultados en el formato indicado
+func writeResults(filename, format string, results []Result, opts OutputOptions) error {
+	switch format {
+	case "xlsx":
+		return writeResultsToExcel(filename, results, opts)
+	case "jsonl":
+		// Sin Columns el JSON completo ya incluye Result.Meta
+		columns := opts.Columns
+		if len(columns) > 0 {
+			columns = withMetaColumns(columns, opts.Passthrough)
+		}
+		return writeResultsToJSONL(filename, results, columns)
+	case "parquet":
+		return writeResultsToParquet(filename, results)
+	default:
+		return fmt.Errorf("formato de salida no soportado: %s", format)
+	}
+}
+
+// Formatos de ProcessingTime en la salida
+const (
+	durationRaw     = "raw"     // 12.3456789s
+	durationMillis  = "ms"      // 12.346s
+	durationSeconds = "s"       // 12.35s
+	durationNumeric = "numeric" // 12.35 (segundos, columna numérica en Excel)
+)
+
+func formatDuration(d time.Duration, format string) string {
+	switch format {
+	case durationMillis:
+		return d.Round(time.Millisecond).String()
+	case durationSeconds:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	case durationNumeric:
+		return strconv.FormatFloat(d.Seconds(), 'f', 2, 64)
+	default:
+		return d.String()
+	}
+}
+
+// Interpretar ProcessingTime en cualquiera de los formatos de formatDuration
+func parseProcessingTime(value string) (time.Duration, bool) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, true
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), true
+	}
+	return 0, false
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Resultados leídos de un archivo JSONL, una línea por resultado
+func readJSONLResults(t *testing.T, path string) []Result {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("error abriendo %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var results []Result
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var result Result
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatalf("línea inválida %q: %v", scanner.Text(), err)
+		}
+		results = append(results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestOutputFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		format   string
+		want     string
+	}{
+		{"-", "", "jsonl"},
+		{"resultados.xlsx", "", "xlsx"},
+		{"resultados.jsonl", "", "jsonl"},
+		{"resultados.NDJSON", "", "jsonl"},
+		{"resultados.parquet", "", "parquet"},
+		{"resultados", "", "xlsx"},
+		{"resultados.xlsx", "JSONL", "jsonl"},
+		{"-", "xlsx", "xlsx"},
+	}
+	for _, tt := range tests {
+		if got := outputFormat(tt.filename, tt.format); got != tt.want {
+			t.Errorf("outputFormat(%q, %q) = %q, se esperaba %q", tt.filename, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestJSONLSinkFlushEvery(t *testing.T) {
+	tests := []struct {
+		name       string
+		flushEvery int
+		writes     int
+		wantLines  int // líneas en el archivo antes de cerrar
+	}{
+		{"tras cada resultado", 0, 3, 3},
+		{"cada dos", 2, 3, 2},
+		{"cada diez", 10, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "salida.jsonl")
+			sink, err := newJSONLSink(path, tt.flushEvery, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cedulas := testCedulas(tt.writes)
+			for _, cedula := range cedulas {
+				if err := sink.Write(Result{Cedula: cedula, Estado: "REGISTRO ACTIVO"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := len(readJSONLResults(t, path)); got != tt.wantLines {
+				t.Errorf("%d líneas antes de cerrar, se esperaban %d", got, tt.wantLines)
+			}
+
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+			results := readJSONLResults(t, path)
+			if len(results) != tt.writes {
+				t.Fatalf("%d líneas al cerrar, se esperaban %d", len(results), tt.writes)
+			}
+			for i, result := range results {
+				if result.Cedula != cedulas[i] {
+					t.Errorf("línea %d: cédula %q, se esperaba %q", i, result.Cedula, cedulas[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteResultsToJSONL(t *testing.T) {
+	results := []Result{
+		{Cedula: "1", PrimerNombre: "JUAN", Estado: "REGISTRO ACTIVO", ProcessingTime: "1.5s"},
+		{Cedula: "2", Estado: "Error", Error: "timeout"},
+	}
+	path := filepath.Join(t.TempDir(), "salida.jsonl")
+	if err := writeResults(path, "jsonl", results, OutputOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readJSONLResults(t, path); !reflect.DeepEqual(got, results) {
+		t.Errorf("leído:\n%+v\nse esperaba:\n%+v", got, results)
+	}
+}
+
+// Las columnas copiadas de la entrada llegan a la salida junto a su cédula
+func TestPassthroughColumnsOutput(t *testing.T) {
+	input := writeExcelInput(t, [][]interface{}{
+		{"Cédula", "Cliente"},
+		{"1000", "ACME"},
+		{"1001", "Globex"},
+		{"1002", "Initech"},
+	})
+	records, err := readInputs(input, "", []string{"cliente"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestScraper(t, testConfig(), okResult)
+	results := s.ProcessInputs(records)
+	want := map[string]string{"1000": "ACME", "1001": "Globex", "1002": "Initech"}
+
+	t.Run("xlsx", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "resultados.xlsx")
+		if err := writeResults(path, "xlsx", results, OutputOptions{Passthrough: []string{"cliente"}}); err != nil {
+			t.Fatal(err)
+		}
+		f, err := excelize.OpenFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		rows, err := f.GetRows(f.GetSheetName(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := rows[0]
+		if last := header[len(header)-1]; last != "cliente" {
+			t.Fatalf("última columna %q, se esperaba %q", last, "cliente")
+		}
+		got := make(map[string]string)
+		for _, row := range rows[1:] {
+			if len(row) == len(header) {
+				got[row[0]] = row[len(row)-1]
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("cliente por cédula = %v, se esperaba %v", got, want)
+		}
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "resultados.jsonl")
+		if err := writeResults(path, "jsonl", results, OutputOptions{Passthrough: []string{"cliente"}}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var r Result
+			if err := json.Unmarshal([]byte(line), &r); err != nil {
+				t.Fatal(err)
+			}
+			got[r.Cedula] = r.Meta["cliente"]
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("cliente por cédula = %v, se esperaba %v", got, want)
+		}
+	})
+}
+
+// Una cédula escrita con puntos se consulta normalizada y la salida conserva
+// ambas formas
+func TestOriginalCedulaOutput(t *testing.T) {
+	input := writeExcelInput(t, [][]interface{}{
+		{"Cédula"},
+		{"1.012.345.678"},
+		{"1023456789"},
+	})
+	records, err := readInputs(input, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var queried []string
+	s := newTestScraper(t, testConfig(), func(cedula string, attempt int) Result {
+		mu.Lock()
+		queried = append(queried, cedula)
+		mu.Unlock()
+		return okResult(cedula, attempt)
+	})
+	results := s.ProcessInputs(records)
+	sort.Strings(queried)
+	if want := []string{"1012345678", "1023456789"}; !reflect.DeepEqual(queried, want) {
+		t.Fatalf("cédulas consultadas %q, se esperaban %q", queried, want)
+	}
+	want := map[string]string{"1012345678": "1.012.345.678", "1023456789": "1023456789"}
+
+	tests := []struct {
+		format string
+		read   func(t *testing.T, path string) []Result
+	}{
+		{"xlsx", func(t *testing.T, path string) []Result {
+			read, err := readResultsFromExcel(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return read
+		}},
+		{"jsonl", func(t *testing.T, path string) []Result {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var read []Result
+			for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+				var r Result
+				if err := json.Unmarshal([]byte(line), &r); err != nil {
+					t.Fatal(err)
+				}
+				read = append(read, r)
+			}
+			return read
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "resultados."+tt.format)
+			if err := writeResults(path, tt.format, results, OutputOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			for _, r := range tt.read(t, path) {
+				got[r.Cedula] = r.OriginalCedula
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("cédula original por cédula = %v, se esperaba %v", got, want)
+			}
+		})
+	}
+}
+
+func TestJSONLSinkFlushInterval(t *testing.T) {
+	tests := []struct {
+		name      string
+		interval  time.Duration
+		wait      time.Duration
+		wantLines int // líneas visibles tras la espera, sin más escrituras
+	}{
+		{"sin intervalo", 0, 100 * time.Millisecond, 0},
+		{"vacía tras el intervalo", 20 * time.Millisecond, 200 * time.Millisecond, 1},
+		{"aún no pasa el intervalo", time.Hour, 100 * time.Millisecond, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "salida.jsonl")
+			// flushEvery alto: solo el intervalo vacía el buffer
+			sink, err := newJSONLSink(path, 100, tt.interval)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Write(Result{Cedula: "1", Estado: "REGISTRO ACTIVO"}); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(tt.wait)
+			if got := len(readJSONLResults(t, path)); got != tt.wantLines {
+				t.Errorf("%d líneas sin nuevas escrituras, se esperaban %d", got, tt.wantLines)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(readJSONLResults(t, path)); got != 1 {
+				t.Errorf("%d líneas al cerrar, se esperaba 1", got)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	d := 12345678900 * time.Nanosecond // 12.3456789s
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{durationRaw, "12.3456789s"},
+		{"", "12.3456789s"},
+		{durationMillis, "12.346s"},
+		{durationSeconds, "12.35s"},
+		{durationNumeric, "12.35"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got := formatDuration(d, tt.format)
+			if got != tt.want {
+				t.Errorf("formatDuration(%q) = %q, se esperaba %q", tt.format, got, tt.want)
+			}
+			// Todos los formatos se pueden volver a leer (resumen, -diff)
+			parsed, ok := parseProcessingTime(got)
+			if !ok {
+				t.Fatalf("parseProcessingTime(%q) no reconoció el valor", got)
+			}
+			if diff := parsed - d; diff < -10*time.Millisecond || diff > 10*time.Millisecond {
+				t.Errorf("parseProcessingTime(%q) = %v, se esperaba %v", got, parsed, d)
+			}
+		})
+	}
+
+	for _, value := range []string{"", "rápido", "12,5"} {
+		if _, ok := parseProcessingTime(value); ok {
+			t.Errorf("parseProcessingTime(%q) aceptó un valor inválido", value)
+		}
+	}
+}
+
+// Sink que no escribe hasta que se cierra release
+type gatedSink struct {
+	memorySink
+	release chan struct{}
+}
+
+func (g *gatedSink) Write(result Result) error {
+	<-g.release
+	return g.memorySink.Write(result)
+}
+
+func TestAsyncSink(t *testing.T) {
+	tests := []struct {
+		name        string
+		overflow    string
+		wantBlocked bool // Write espera con el buffer lleno
+		wantSpill   bool
+	}{
+		{name: "esperar", overflow: sinkOverflowBlock, wantBlocked: true},
+		{name: "por defecto", overflow: "", wantBlocked: true},
+		{name: "desbordar a disco", overflow: sinkOverflowSpill, wantSpill: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &gatedSink{release: make(chan struct{})}
+			sink, err := newAsyncSink(inner, 2, tt.overflow)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Uno queda en la goroutine del sink, dos en el buffer y el resto
+			// espera o se desborda
+			var written atomic.Int32
+			writesDone := make(chan struct{})
+			go func() {
+				defer close(writesDone)
+				for _, cedula := range testCedulas(5) {
+					if err := sink.Write(Result{Cedula: cedula}); err != nil {
+						t.Errorf("Write: %v", err)
+					}
+					written.Add(1)
+				}
+			}()
+
+			select {
+			case <-writesDone:
+				if tt.wantBlocked {
+					t.Error("Write no esperó con el buffer lleno")
+				}
+			case <-time.After(100 * time.Millisecond):
+				if !tt.wantBlocked {
+					t.Errorf("Write se bloqueó tras %d resultados; se esperaba desbordar", written.Load())
+				}
+			}
+			close(inner.release)
+			<-writesDone
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if (sink.spilled > 0) != tt.wantSpill {
+				t.Errorf("%d resultados desbordados; se esperaba desborde: %v", sink.spilled, tt.wantSpill)
+			}
+			if got := len(inner.cedulas()); got != 5 {
+				t.Errorf("el sink interno recibió %d resultados, se esperaban 5", got)
+			}
+			if !inner.closed {
+				t.Error("no se cerró el sink interno")
+			}
+		})
+	}
+
+	if _, err := newAsyncSink(&memorySink{}, 1, "descartar"); err == nil {
+		t.Error("se esperaba error con un modo de desborde desconocido")
+	}
+}
+
+func TestCreateRunDir(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "ejecuciones")
+	now := time.Date(2024, 1, 31, 15, 4, 5, 0, time.UTC)
+
+	dir, err := createRunDir(base, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(base, "2024-01-31T15-04-05"); dir != want {
+		t.Errorf("directorio = %q, se esperaba %q", dir, want)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("no se creó el directorio %s: %v", dir, err)
+	}
+	// Crear el mismo directorio dos veces no es un error
+	if _, err := createRunDir(base, now); err != nil {
+		t.Errorf("segunda creación: %v", err)
+	}
+}
+
+func TestRunPath(t *testing.T) {
+	abs, err := filepath.Abs("resultados.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		runDir string
+		path   string
+		want   string
+	}{
+		{"sin directorio", "", "resultados.xlsx", "resultados.xlsx"},
+		{"relativa", "salida/2024-01-31T15-04-05", "resultados.xlsx", filepath.Join("salida/2024-01-31T15-04-05", "resultados.xlsx")},
+		{"subdirectorio", "salida", "capturas/a.png", filepath.Join("salida", "capturas/a.png")},
+		{"absoluta", "salida", abs, abs},
+		{"salida estándar", "salida", "-", "-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runPath(tt.runDir, tt.path); got != tt.want {
+				t.Errorf("runPath(%q, %q) = %q, se esperaba %q", tt.runDir, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveOutputPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string // archivos que ya existen en el directorio
+		mode     string
+		want     string
+		wantErr  bool
+	}{
+		{name: "no existe", mode: ifExistsError, want: "resultados.xlsx"},
+		{name: "sobrescribir", existing: []string{"resultados.xlsx"}, mode: ifExistsOverwrite, want: "resultados.xlsx"},
+		{name: "modo por defecto", existing: []string{"resultados.xlsx"}, mode: "", want: "resultados.xlsx"},
+		{name: "error si existe", existing: []string{"resultados.xlsx"}, mode: ifExistsError, wantErr: true},
+		{name: "renombrar", existing: []string{"resultados.xlsx"}, mode: ifExistsRename, want: "resultados_1.xlsx"},
+		{
+			name:     "renombrar saltando los usados",
+			existing: []string{"resultados.xlsx", "resultados_1.xlsx", "resultados_2.xlsx"},
+			mode:     ifExistsRename,
+			want:     "resultados_3.xlsx",
+		},
+		{name: "modo desconocido", existing: []string{"resultados.xlsx"}, mode: "append", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.existing {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("previo"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := resolveOutputPath(filepath.Join(dir, "resultados.xlsx"), tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != filepath.Join(dir, tt.want) {
+				t.Errorf("ruta = %q, se esperaba %q", filepath.Base(got), tt.want)
+			}
+		})
+	}
+
+	if got, err := resolveOutputPath("-", ifExistsError); err != nil || got != "-" {
+		t.Errorf(`resolveOutputPath("-") = %q, %v; se esperaba "-"`, got, err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Fila Parquet con las mismas columnas (y nombres) que el JSON de Result
+type parquetRow struct {
+	Cedula           string `parquet:"name=cedula, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OriginalCedula   string `parquet:"name=originalCedula, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PrimerApellido   string `parquet:"name=primerApellido, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SegundoApellido  string `parquet:"name=segundoApellido, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PrimerNombre     string `parquet:"name=primerNombre, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SegundoNombre    string `parquet:"name=segundoNombre, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Estado           string `parquet:"name=estado, type=BYTE_ARRAY, convertedtype=UTF8"`
+	FechaInscripcion string `parquet:"name=fechaInscripcion, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Attempts         int32  `parquet:"name=attempts, type=INT32"`
+	Error            string `parquet:"name=error, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ErrorCode        string `parquet:"name=errorCode, type=BYTE_ARRAY, convertedtype=UTF8"`
+	HTTPStatus       int32  `parquet:"name=httpStatus, type=INT32"`
+	Captchas         int32  `parquet:"name=captchas, type=INT32"`
+	CaptchaRequired  bool   `parquet:"name=captchaRequired, type=BOOLEAN"`
+	ProcessingTime   string `parquet:"name=processingTime, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Source           string `parquet:"name=source, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+func newParquetRow(result Result) parquetRow {
+	return parquetRow{
+		Cedula:           result.Cedula,
+		OriginalCedula:   result.OriginalCedula,
+		PrimerApellido:   result.PrimerApellido,
+		SegundoApellido:  result.SegundoApellido,
+		PrimerNombre:     result.PrimerNombre,
+		SegundoNombre:    result.SegundoNombre,
+		Estado:           result.Estado,
+		FechaInscripcion: result.FechaInscripcion,
+		Attempts:         int32(result.Attempts),
+		Error:            result.Error,
+		ErrorCode:        result.ErrorCode,
+		HTTPStatus:       int32(result.HTTPStatus),
+		Captchas:         int32(result.Captchas),
+		CaptchaRequired:  result.CaptchaRequired,
+		ProcessingTime:   result.ProcessingTime,
+		Source:           result.Source,
+	}
+}
+
+// Escribir los resultados en Parquet (compresión Snappy) para cargarlos en
+// bodegas de datos
+func writeResultsToParquet(filename string, results []Result) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creando archivo Parquet: %v", err)
+	}
+	defer f.Close()
+
+	pw, err := writer.NewParquetWriterFromWriter(f, new(parquetRow), 1)
+	if err != nil {
+		return fmt.Errorf("error creando escritor Parquet: %v", err)
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+
+	for _, result := range results {
+		if err := pw.Write(newParquetRow(result)); err != nil {
+			return fmt.Errorf("error escribiendo fila Parquet: %v", err)
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		return fmt.Errorf("error cerrando archivo Parquet: %v", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestWriteResultsToParquet(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []Result
+	}{
+		{"vacío", nil},
+		{
+			name: "con resultados",
+			results: []Result{
+				{Cedula: "1012345678", PrimerApellido: "PÉREZ", PrimerNombre: "JUAN", Estado: "REGISTRO ACTIVO",
+					FechaInscripcion: "2015-03-05", Attempts: 1, CaptchaRequired: true, Captchas: 1, Source: "entrada.xlsx:Hoja1:2"},
+				{Cedula: "79123456", Estado: "Error", Error: "Error navegando: net::ERR_CONNECTION_RESET",
+					ErrorCode: errCodeNetwork, Attempts: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "resultados.parquet")
+			if err := writeResultsToParquet(path, tt.results); err != nil {
+				t.Fatal(err)
+			}
+
+			fr, err := local.NewLocalFileReader(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fr.Close()
+			pr, err := reader.NewParquetReader(fr, new(parquetRow), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pr.ReadStop()
+
+			n := int(pr.GetNumRows())
+			if n != len(tt.results) {
+				t.Fatalf("%d filas, se esperaban %d", n, len(tt.results))
+			}
+			rows := make([]parquetRow, n)
+			if n > 0 {
+				if err := pr.Read(&rows); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i, result := range tt.results {
+				if want := newParquetRow(result); !reflect.DeepEqual(rows[i], want) {
+					t.Errorf("fila %d = %+v, se esperaba %+v", i, rows[i], want)
+				}
+			}
+		})
+	}
+}
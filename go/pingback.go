@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tiempo que se guarda una respuesta que nadie espera (llegó antes que el
+// waiter o después de que se le acabara el tiempo)
+const pingbackAnswerTTL = 10 * time.Minute
+
+// Servidor HTTP que recibe las respuestas de 2captcha vía pingback,
+// evitando tener que consultar res.php periódicamente. Solo acepta
+// peticiones con el token que va en la URL registrada en 2captcha
+type pingbackServer struct {
+	mu      sync.Mutex
+	answers map[string]pingbackAnswer
+	waiters map[string]chan string
+	token   string
+	srv     *http.Server
+	now     func() time.Time
+}
+
+type pingbackAnswer struct {
+	code     string
+	received time.Time
+}
+
+// Iniciar el servidor de pingback en la dirección indicada (ej. ":8089")
+func startPingbackServer(addr, token string) (*pingbackServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error iniciando servidor de pingback: %v", err)
+	}
+
+	p := newPingbackServer(token)
+	p.srv = &http.Server{Handler: p, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := p.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Printf("Servidor de pingback detenido: %v", err)
+		}
+	}()
+
+	log.Printf("Servidor de pingback escuchando en %s", ln.Addr())
+	return p, nil
+}
+
+func newPingbackServer(token string) *pingbackServer {
+	return &pingbackServer{
+		answers: make(map[string]pingbackAnswer),
+		waiters: make(map[string]chan string),
+		token:   token,
+		now:     time.Now,
+	}
+}
+
+// Token aleatorio para la URL de pingback
+func newPingbackToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generando token de pingback: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// URL de pingback con el token como parámetro "token"
+func pingbackURLWithToken(base, token string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("URL de pingback inválida %q: %v", base, err)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// 2captcha envía id y code del captcha resuelto
+func (p *pingbackServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "formulario inválido", http.StatusBadRequest)
+		return
+	}
+	// Sin el token cualquiera podría inyectar respuestas de captcha
+	if subtle.ConstantTimeCompare([]byte(r.FormValue("token")), []byte(p.token)) != 1 {
+		http.Error(w, "token inválido", http.StatusForbidden)
+		return
+	}
+
+	id := r.FormValue("id")
+	code := r.FormValue("code")
+	if id == "" {
+		http.Error(w, "falta id", http.StatusBadRequest)
+		return
+	}
+
+	p.deliver(id, code)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (p *pingbackServer) deliver(id, code string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if ch, ok := p.waiters[id]; ok {
+		ch <- code
+		delete(p.waiters, id)
+		return
+	}
+	// La respuesta puede llegar antes de que alguien la espere, o tarde; las
+	// que nadie recoge se descartan pasado pingbackAnswerTTL
+	now := p.now()
+	for other, answer := range p.answers {
+		if now.Sub(answer.received) > pingbackAnswerTTL {
+			delete(p.answers, other)
+		}
+	}
+	p.answers[id] = pingbackAnswer{code: code, received: now}
+}
+
+// Esperar la respuesta para un captcha; devuelve false si no llegó a tiempo
+func (p *pingbackServer) wait(id string, timeout time.Duration) (string, bool) {
+	p.mu.Lock()
+	if answer, ok := p.answers[id]; ok {
+		delete(p.answers, id)
+		p.mu.Unlock()
+		return answer.code, true
+	}
+	ch := make(chan string, 1)
+	p.waiters[id] = ch
+	p.mu.Unlock()
+
+	select {
+	case code := <-ch:
+		return code, true
+	case <-time.After(timeout):
+		p.mu.Lock()
+		delete(p.waiters, id)
+		p.mu.Unlock()
+		return "", false
+	}
+}
+
+func (p *pingbackServer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p.srv.Shutdown(ctx)
+}
+
+// Las respuestas de error de 2captcha llegan con prefijo ERROR_
+func isCaptchaErrorCode(code string) bool {
+	return strings.HasPrefix(code, "ERROR_")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPingbackServeHTTP(t *testing.T) {
+	tests := []struct {
+		name       string
+		form       url.Values
+		wantStatus int
+		wantCode   string
+	}{
+		{"token válido", url.Values{"token": {"secreto"}, "id": {"42"}, "code": {"abc12"}}, http.StatusOK, "abc12"},
+		{"sin token", url.Values{"id": {"42"}, "code": {"abc12"}}, http.StatusForbidden, ""},
+		{"token incorrecto", url.Values{"token": {"otro"}, "id": {"42"}, "code": {"abc12"}}, http.StatusForbidden, ""},
+		{"sin id", url.Values{"token": {"secreto"}, "code": {"abc12"}}, http.StatusBadRequest, ""},
+		{"código de error", url.Values{"token": {"secreto"}, "id": {"42"}, "code": {"ERROR_CAPTCHA_UNSOLVABLE"}}, http.StatusOK, "ERROR_CAPTCHA_UNSOLVABLE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPingbackServer("secreto")
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, se esperaba %d", rec.Code, tt.wantStatus)
+			}
+			code, ok := p.wait("42", 10*time.Millisecond)
+			if tt.wantCode == "" {
+				if ok {
+					t.Errorf("se entregó la respuesta %q de una petición rechazada", code)
+				}
+				return
+			}
+			if !ok || code != tt.wantCode {
+				t.Errorf("wait = %q, %v; se esperaba %q", code, ok, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestPingbackWaitBeforeDeliver(t *testing.T) {
+	p := newPingbackServer("secreto")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		p.deliver("7", "xyz")
+	}()
+	code, ok := p.wait("7", time.Second)
+	if !ok || code != "xyz" {
+		t.Errorf("wait = %q, %v; se esperaba \"xyz\"", code, ok)
+	}
+}
+
+func TestPingbackWaitTimeout(t *testing.T) {
+	p := newPingbackServer("secreto")
+	if _, ok := p.wait("7", 10*time.Millisecond); ok {
+		t.Fatal("wait devolvió una respuesta que nunca llegó")
+	}
+	if len(p.waiters) != 0 {
+		t.Errorf("quedaron %d waiters tras el timeout", len(p.waiters))
+	}
+}
+
+func TestPingbackAnswerTTL(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	p := newPingbackServer("secreto")
+	p.now = func() time.Time { return now }
+
+	p.deliver("viejo", "aaa")
+	now = now.Add(pingbackAnswerTTL + time.Second)
+	p.deliver("nuevo", "bbb")
+
+	if _, ok := p.answers["viejo"]; ok {
+		t.Error("la respuesta sin recoger no se descartó pasado el TTL")
+	}
+	if code, ok := p.wait("nuevo", 10*time.Millisecond); !ok || code != "bbb" {
+		t.Errorf("wait(nuevo) = %q, %v; se esperaba \"bbb\"", code, ok)
+	}
+}
+
+func TestPingbackURLWithToken(t *testing.T) {
+	tests := []struct {
+		base string
+		want string
+	}{
+		{"https://ejemplo.com/pingback", "https://ejemplo.com/pingback?token=t0k"},
+		{"https://ejemplo.com/pingback?a=1", "https://ejemplo.com/pingback?a=1&token=t0k"},
+		{"https://ejemplo.com/pingback?token=viejo", "https://ejemplo.com/pingback?token=t0k"},
+	}
+	for _, tt := range tests {
+		got, err := pingbackURLWithToken(tt.base, "t0k")
+		if err != nil {
+			t.Fatalf("pingbackURLWithToken(%q): %v", tt.base, err)
+		}
+		if got != tt.want {
+			t.Errorf("pingbackURLWithToken(%q) = %q, se esperaba %q", tt.base, got, tt.want)
+		}
+	}
+	if _, err := pingbackURLWithToken("://sin-esquema", "t0k"); err == nil {
+		t.Error("se esperaba error con una URL inválida")
+	}
+}
+
+func TestNewPingbackToken(t *testing.T) {
+	a, err := newPingbackToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newPingbackToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 32 || a == b {
+		t.Errorf("tokens %q y %q: se esperaban 32 caracteres hex distintos", a, b)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// Iniciar el perfil de CPU en filename; la función devuelta lo detiene y
+// cierra el archivo
+func startCPUProfile(filename string) (func(), error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error creando perfil de CPU: %v", err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error iniciando perfil de CPU: %v", err)
+	}
+	return func() {
+		pprof.StopCPUProfile()
+		f.Close()
+		log.Printf("Perfil de CPU guardado en: %s", filename)
+	}, nil
+}
+
+// Escribir el perfil de memoria (heap) tal como está al terminar
+func writeMemProfile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creando perfil de memoria: %v", err)
+	}
+	defer f.Close()
+
+	runtime.GC() // estadísticas de memoria al día
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("error escribiendo perfil de memoria: %v", err)
+	}
+	log.Printf("Perfil de memoria guardado en: %s", filename)
+	return nil
+}
+
+// Servir /debug/pprof/ en addr para perfilar en vivo una ejecución larga
+func startPprofServer(addr string) {
+	go func() {
+		log.Printf("Perfiles en vivo en http://%s/debug/pprof/", addr)
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			log.Printf("Error en servidor pprof: %v", err)
+		}
+	}()
+}
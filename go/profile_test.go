@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Los perfiles de pprof son protobuf comprimido con gzip
+func checkProfile(t *testing.T, path string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		t.Errorf("%s no es un perfil pprof (%d bytes)", filepath.Base(path), len(data))
+	}
+}
+
+func TestStartCPUProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    func(dir string) string
+		wantErr bool
+	}{
+		{"archivo nuevo", func(dir string) string { return filepath.Join(dir, "cpu.pprof") }, false},
+		{"directorio inexistente", func(dir string) string { return filepath.Join(dir, "no-existe", "cpu.pprof") }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.path(t.TempDir())
+			stop, err := startCPUProfile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			s := newTestScraper(t, testConfig(), okResult)
+			s.ProcessCedulas(testCedulas(20))
+			stop()
+			checkProfile(t, path)
+		})
+	}
+}
+
+func TestWriteMemProfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mem.pprof")
+	if err := writeMemProfile(path); err != nil {
+		t.Fatal(err)
+	}
+	checkProfile(t, path)
+
+	if err := writeMemProfile(filepath.Join(dir, "no-existe", "mem.pprof")); err == nil {
+		t.Error("se esperaba error con un directorio inexistente")
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Contadores de un proxy
+type ProxyStats struct {
+	Proxy       string
+	Requests    int
+	Failures    int
+	Quarantines int
+	// Hasta cuándo se salta en la rotación (cero = disponible)
+	QuarantinedUntil time.Time
+}
+
+// Proxies de los navegadores con sus contadores. Un proxy que acumula
+// maxFailures fallos seguidos queda en cuarentena durante cooldown y no se
+// asigna a ningún navegador nuevo (maxFailures 0 = sin cuarentena)
+type proxyPool struct {
+	mu          sync.Mutex
+	proxies     []*proxyState
+	maxFailures int
+	cooldown    time.Duration
+	now         func() time.Time
+}
+
+type proxyState struct {
+	ProxyStats
+	consecutive int // Fallos seguidos desde el último éxito
+}
+
+// nil si no hay proxies: los navegadores usan conexión directa
+func newProxyPool(proxies []string, maxFailures int, cooldown time.Duration) *proxyPool {
+	if len(proxies) == 0 {
+		return nil
+	}
+	p := &proxyPool{maxFailures: maxFailures, cooldown: cooldown, now: time.Now}
+	for _, proxy := range proxies {
+		p.proxies = append(p.proxies, &proxyState{ProxyStats: ProxyStats{Proxy: proxy}})
+	}
+	return p
+}
+
+// Proxy para el worker idx: se reparten en orden, así que con tantos
+// workers como proxies cada navegador usa uno distinto. Los proxies en
+// cuarentena se saltan; si todos lo están se usa el que sale antes.
+// Vacío = conexión directa
+func (p *proxyPool) pick(idx int) string {
+	if p == nil {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	n := len(p.proxies)
+	var soonest *proxyState
+	for i := 0; i < n; i++ {
+		ps := p.proxies[(idx+i)%n]
+		if !now.Before(ps.QuarantinedUntil) {
+			return ps.Proxy
+		}
+		if soonest == nil || ps.QuarantinedUntil.Before(soonest.QuarantinedUntil) {
+			soonest = ps
+		}
+	}
+	log.Printf("ADVERTENCIA: todos los proxies están en cuarentena; se usa %s", proxyLabel(soonest.Proxy))
+	return soonest.Proxy
+}
+
+// Registrar una consulta hecha por proxy. Devuelve true si con este fallo
+// el proxy entró en cuarentena
+func (p *proxyPool) record(proxy string, failed bool) bool {
+	if p == nil || proxy == "" {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, ps := range p.proxies {
+		if ps.Proxy != proxy {
+			continue
+		}
+		ps.Requests++
+		if !failed {
+			ps.consecutive = 0
+			return false
+		}
+		ps.Failures++
+		ps.consecutive++
+		if p.maxFailures <= 0 || ps.consecutive < p.maxFailures {
+			return false
+		}
+		ps.consecutive = 0
+		ps.Quarantines++
+		ps.QuarantinedUntil = p.now().Add(p.cooldown)
+		log.Printf("Proxy %s en cuarentena por %v tras %d fallos seguidos", proxyLabel(proxy), p.cooldown, p.maxFailures)
+		return true
+	}
+	return false
+}
+
+// Poner el proxy en cuarentena sin esperar a maxFailures fallos seguidos:
+// un error TLS no se arregla reintentando por el mismo proxy
+func (p *proxyPool) quarantine(proxy, reason string) {
+	if p == nil || proxy == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, ps := range p.proxies {
+		if ps.Proxy != proxy {
+			continue
+		}
+		ps.Requests++
+		ps.Failures++
+		ps.consecutive = 0
+		ps.Quarantines++
+		ps.QuarantinedUntil = p.now().Add(p.cooldown)
+		log.Printf("Proxy %s en cuarentena por %v: %s", proxyLabel(proxy), p.cooldown, reason)
+		return
+	}
+}
+
+// Copia de los contadores, en el orden de ProxyList
+func (p *proxyPool) snapshot() []ProxyStats {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]ProxyStats, len(p.proxies))
+	for i, ps := range p.proxies {
+		out[i] = ps.ProxyStats
+	}
+	return out
+}
+
+// Códigos de error atribuibles al proxy (red, bloqueo de la IP) y no a la
+// cédula o a la DIAN
+func proxyFailure(result Result) bool {
+	switch result.ErrorCode {
+	case errCodeNetwork, errCodeTimeout, errCodeTLS, errCodeBlocked, errCodeSilentBlock, errCodeRateLimited:
+		return true
+	}
+	return false
+}
+
+// Proxy sin usuario ni contraseña, para los logs
+func proxyLabel(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return proxy
+	}
+	u.User = nil
+	return u.String()
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxBrowsers(t *testing.T) {
+	proxies := []string{"http://p1:3128", "http://p2:3128", "http://p3:3128"}
+	tests := []struct {
+		name        string
+		cpus        int
+		maxParallel int
+		proxies     []string
+		limit       bool
+		want        int
+	}{
+		{"solo CPUs", 8, 0, nil, false, 8},
+		{"límite de navegadores", 8, 4, nil, false, 4},
+		{"límite mayor que las CPUs", 2, 4, nil, false, 2},
+		{"menos proxies que CPUs", 8, 0, proxies, true, 3},
+		{"menos proxies sin la opción", 8, 0, proxies, false, 8},
+		{"límite de navegadores menor que los proxies", 8, 2, proxies, true, 2},
+		{"más proxies que CPUs", 2, 0, proxies, true, 2},
+		{"opción sin proxies", 8, 0, nil, true, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MaxParallelBrowsers = tt.maxParallel
+			config.ProxyList = tt.proxies
+			config.LimitBrowsersToProxies = tt.limit
+			s := newTestScraper(t, config, okResult)
+
+			if got := s.maxBrowsers(tt.cpus); got != tt.want {
+				t.Errorf("maxBrowsers(%d) = %d, se esperaba %d", tt.cpus, got, tt.want)
+			}
+		})
+	}
+}
+
+// Con tantos workers como proxies cada navegador usa uno distinto
+func TestProxyPoolPick(t *testing.T) {
+	proxies := []string{"http://p1:3128", "http://p2:3128", "http://p3:3128"}
+	tests := []struct {
+		name    string
+		proxies []string
+		workers int
+		want    []string
+	}{
+		{"uno por worker", proxies, 3, proxies},
+		{"más workers que proxies", proxies, 5, append(append([]string(nil), proxies...), proxies[:2]...)},
+		{"sin proxies", nil, 2, []string{"", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newProxyPool(tt.proxies, 0, 0)
+			for idx := 0; idx < tt.workers; idx++ {
+				if got := pool.pick(idx); got != tt.want[idx] {
+					t.Errorf("pick(%d) = %q, se esperaba %q", idx, got, tt.want[idx])
+				}
+			}
+		})
+	}
+}
+
+func TestProxyPoolRecord(t *testing.T) {
+	const cooldown = 5 * time.Minute
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		maxFailures     int
+		outcomes        []bool // true = fallo
+		wantQuarantined []bool // resultado de cada record
+		want            ProxyStats
+	}{
+		{
+			name:            "fallos seguidos",
+			maxFailures:     2,
+			outcomes:        []bool{true, true},
+			wantQuarantined: []bool{false, true},
+			want:            ProxyStats{Requests: 2, Failures: 2, Quarantines: 1, QuarantinedUntil: start.Add(cooldown)},
+		},
+		{
+			name:            "un éxito reinicia la cuenta",
+			maxFailures:     2,
+			outcomes:        []bool{true, false, true, false},
+			wantQuarantined: []bool{false, false, false, false},
+			want:            ProxyStats{Requests: 4, Failures: 2},
+		},
+		{
+			name:            "otra cuarentena tras la primera",
+			maxFailures:     2,
+			outcomes:        []bool{true, true, true, true},
+			wantQuarantined: []bool{false, true, false, true},
+			want:            ProxyStats{Requests: 4, Failures: 4, Quarantines: 2, QuarantinedUntil: start.Add(cooldown)},
+		},
+		{
+			name:            "sin cuarentena",
+			maxFailures:     0,
+			outcomes:        []bool{true, true, true},
+			wantQuarantined: []bool{false, false, false},
+			want:            ProxyStats{Requests: 3, Failures: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newProxyPool([]string{"http://p1:3128", "http://p2:3128"}, tt.maxFailures, cooldown)
+			pool.now = func() time.Time { return start }
+			for i, failed := range tt.outcomes {
+				if got := pool.record("http://p1:3128", failed); got != tt.wantQuarantined[i] {
+					t.Errorf("record #%d = %v, se esperaba %v", i+1, got, tt.wantQuarantined[i])
+				}
+			}
+			stats := pool.snapshot()
+			tt.want.Proxy = "http://p1:3128"
+			if stats[0] != tt.want {
+				t.Errorf("contadores = %+v, se esperaba %+v", stats[0], tt.want)
+			}
+			// El otro proxy no se ve afectado
+			if stats[1] != (ProxyStats{Proxy: "http://p2:3128"}) {
+				t.Errorf("contadores del otro proxy = %+v", stats[1])
+			}
+		})
+	}
+}
+
+// Sin proxies o con una consulta directa no se registra nada
+func TestProxyPoolRecordDirect(t *testing.T) {
+	var nilPool *proxyPool
+	if nilPool.record("", true) || nilPool.snapshot() != nil {
+		t.Error("un pool vacío registró la consulta")
+	}
+	pool := newProxyPool([]string{"http://p1:3128"}, 1, time.Minute)
+	if pool.record("", true) || pool.record("http://otro:3128", true) {
+		t.Error("una consulta sin proxy del pool puso un proxy en cuarentena")
+	}
+	if stats := pool.snapshot(); stats[0].Requests != 0 {
+		t.Errorf("contadores = %+v, se esperaba ninguna consulta", stats[0])
+	}
+}
+
+// Los proxies en cuarentena se saltan hasta que vence el plazo; si todos lo
+// están se usa el que sale antes
+func TestProxyPoolPickQuarantine(t *testing.T) {
+	const cooldown = time.Minute
+	proxies := []string{"http://p1:3128", "http://p2:3128", "http://p3:3128"}
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		quarantined []int         // índices en cuarentena, en orden
+		elapsed     time.Duration // tiempo transcurrido al elegir
+		want        []string      // pick(0), pick(1), pick(2)
+	}{
+		{"ninguno", nil, 0, proxies},
+		{"uno", []int{0}, 0, []string{proxies[1], proxies[1], proxies[2]}},
+		{"dos", []int{0, 1}, 0, []string{proxies[2], proxies[2], proxies[2]}},
+		{"todos", []int{1, 0, 2}, 0, []string{proxies[1], proxies[1], proxies[1]}},
+		{"cuarentena vencida", []int{0, 1}, cooldown, proxies},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newProxyPool(proxies, 1, cooldown)
+			now := start
+			pool.now = func() time.Time { return now }
+			for _, idx := range tt.quarantined {
+				if !pool.record(proxies[idx], true) {
+					t.Fatalf("%s no entró en cuarentena", proxies[idx])
+				}
+				now = now.Add(time.Second)
+			}
+			now = now.Add(tt.elapsed)
+			for idx, want := range tt.want {
+				if got := pool.pick(idx); got != want {
+					t.Errorf("pick(%d) = %q, se esperaba %q", idx, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProxyFailure(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{errCodeNetwork, true},
+		{errCodeTimeout, true},
+		{errCodeTLS, true},
+		{errCodeBlocked, true},
+		{errCodeSilentBlock, true},
+		{errCodeRateLimited, true},
+		{errCodeSessionLimit, false},
+		{errCodeFallback, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := proxyFailure(Result{Estado: "Error", ErrorCode: tt.code}); got != tt.want {
+			t.Errorf("proxyFailure(%q) = %v, se esperaba %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestProxyLabel(t *testing.T) {
+	tests := []struct {
+		proxy, want string
+	}{
+		{"http://p1:3128", "http://p1:3128"},
+		{"http://usuario:clave@p1:3128", "http://p1:3128"},
+		{"socks5://usuario@p2:1080", "socks5://p2:1080"},
+		{"p3:3128", "p3:3128"},
+	}
+	for _, tt := range tests {
+		if got := proxyLabel(tt.proxy); got != tt.want {
+			t.Errorf("proxyLabel(%q) = %q, se esperaba %q", tt.proxy, got, tt.want)
+		}
+	}
+}
+
+// Un proxy que entra en cuarentena hace que el worker reinicie el navegador
+// con otro proxy
+func TestProxyQuarantineRestartsBrowser(t *testing.T) {
+	config := testConfig()
+	config.Concurrency = 1
+	config.ProxyList = []string{"http://p1:3128", "http://p2:3128"}
+	config.MaxProxyFailures = 2
+	config.ProxyQuarantine = time.Hour
+	config.TimeoutConfig.MaxRetries = 1
+	s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+		return Result{Estado: "Error", Error: "conexión rechazada", ErrorCode: errCodeNetwork}
+	})
+	var launches atomic.Int32
+	s.launch = func(context.Context) error {
+		launches.Add(1)
+		return nil
+	}
+
+	results := s.ProcessCedulas(testCedulas(4))
+	if len(results) != 4 {
+		t.Fatalf("%d resultados, se esperaban 4", len(results))
+	}
+	stats := s.ProxyStats()
+	for _, ps := range stats {
+		if ps.Requests != 2 || ps.Quarantines != 1 {
+			t.Errorf("contadores de %s = %+v, se esperaban 2 consultas y 1 cuarentena", ps.Proxy, ps)
+		}
+	}
+	if n := launches.Load(); n < 2 {
+		t.Errorf("%d navegadores iniciados, se esperaba un reinicio con otro proxy", n)
+	}
+}
+
+// Un error TLS con proxy pone el proxy en cuarentena de inmediato y el
+// reintento va por un navegador nuevo sin proxy; sin proxy se reintenta
+// en el mismo navegador
+func TestTLSErrorRetriesWithoutProxy(t *testing.T) {
+	tests := []struct {
+		name            string
+		proxies         []string
+		wantLaunches    int32
+		wantQuarantines int
+	}{
+		{"con proxy", []string{"http://p1:3128"}, 2, 1},
+		{"sin proxy", nil, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Concurrency = 1
+			config.ProxyList = tt.proxies
+			config.MaxProxyFailures = 5
+			config.ProxyQuarantine = time.Hour
+			config.TimeoutConfig.MaxRetries = 3
+			s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+				if attempt == 1 {
+					return Result{Estado: "Error", Error: "Error de certificado TLS al navegar", ErrorCode: errCodeTLS}
+				}
+				return okResult(cedula, attempt)
+			})
+			var launches atomic.Int32
+			s.launch = func(context.Context) error {
+				launches.Add(1)
+				return nil
+			}
+
+			results := s.ProcessCedulas(testCedulas(3))
+			for _, result := range results {
+				if result.ErrorCode != "" || result.Attempts != 2 {
+					t.Errorf("cédula %s: ErrorCode %q en %d intentos; se esperaba éxito en el intento 2",
+						result.Cedula, result.ErrorCode, result.Attempts)
+				}
+			}
+			if n := launches.Load(); n != tt.wantLaunches {
+				t.Errorf("%d navegadores iniciados, se esperaban %d", n, tt.wantLaunches)
+			}
+			quarantines := 0
+			for _, ps := range s.ProxyStats() {
+				quarantines += ps.Quarantines
+				// Tras la cuarentena ninguna consulta vuelve a pasar por el proxy
+				if ps.Requests != 1 {
+					t.Errorf("%d consultas por %s, se esperaba 1", ps.Requests, ps.Proxy)
+				}
+			}
+			if quarantines != tt.wantQuarantines {
+				t.Errorf("%d cuarentenas, se esperaban %d", quarantines, tt.wantQuarantines)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Datos de una página guardada, leídos sin navegador: las mismas reglas que
+// processCedula (vista, mensaje de la DIAN, campos por ID y, si faltan, las
+// expresiones de respaldo sobre el texto visible)
+func extractFromHTML(page []byte) Result {
+	return extractFromHTMLWith(page, defaultFallbackRegexps)
+}
+
+var defaultFallbackRegexps = mustCompileFallbackPatterns(defaultFallbackPatterns)
+
+func extractFromHTMLWith(page []byte, fallback map[string]*regexp.Regexp) Result {
+	var result Result
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		result.Estado = "Error"
+		result.Error = fmt.Sprintf("Error leyendo HTML: %v", err)
+		return result
+	}
+
+	// Texto de cada elemento con ID y de los mensajes de la DIAN
+	byID := make(map[string]string)
+	var messages, errorSummaries []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if id := htmlAttr(n, "id"); id != "" {
+				// Los campos de texto guardan el valor en el atributo
+				if n.Data == "input" {
+					byID[id] = htmlAttr(n, "value")
+				} else {
+					byID[id] = nodeText(n)
+				}
+			}
+			classes := " " + htmlAttr(n, "class") + " "
+			for _, class := range []string{"ui-messages-error-summary", "ui-messages-warn-summary", "ui-messages-info-summary",
+				"ui-messages-error-detail", "ui-messages-warn-detail", "ui-messages-info-detail"} {
+				if strings.Contains(classes, " "+class+" ") {
+					messages = append(messages, nodeText(n))
+					if class == "ui-messages-error-summary" {
+						errorSummaries = append(errorSummaries, nodeText(n))
+					}
+					break
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	if isSinRUTMessage(strings.Join(messages, " ")) {
+		result.Estado = estadoSinRUT
+		return result
+	}
+	if len(errorSummaries) > 0 {
+		result.Estado = "Error"
+		result.Error = strings.TrimSpace(errorSummaries[0])
+		return result
+	}
+
+	field := func(name string) (string, bool) {
+		if text, ok := byID[consultFormPrefix+name]; ok {
+			return text, true
+		}
+		// Vista de detalle: el ID termina en el nombre del campo
+		suffix := ":" + name
+		ids := make([]string, 0, len(byID))
+		for id := range byID {
+			if strings.HasSuffix(id, suffix) {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			return "", false
+		}
+		sort.Strings(ids)
+		return byID[ids[0]], true
+	}
+
+	numNit, _ := field("numNit")
+	result.Cedula = strings.TrimSpace(numNit)
+	estado, found := field("estado")
+	result.PrimerApellido, _ = field("primerApellido")
+	result.SegundoApellido, _ = field("segundoApellido")
+	result.PrimerNombre, _ = field("primerNombre")
+	result.SegundoNombre, _ = field("otrosNombres")
+	fecha, _ := field("fechaInscripcion")
+	result.FechaInscripcion = normalizeFecha(fecha)
+	result.Estado = strings.TrimSpace(estado)
+
+	if !found && fallback != nil {
+		values := extractFromText(nodeText(doc), fallback)
+		if values["estado"] != "" {
+			result.PrimerApellido = values["primerapellido"]
+			result.SegundoApellido = values["segundoapellido"]
+			result.PrimerNombre = values["primernombre"]
+			result.SegundoNombre = values["segundonombre"]
+			result.Estado = values["estado"]
+			result.ErrorCode = errCodeFallback
+			return result
+		}
+	}
+	if result.Estado == "" {
+		result.Estado = "Error"
+		result.Error = "El HTML no tiene datos de la consulta"
+	}
+	return result
+}
+
+func mustCompileFallbackPatterns(patterns map[string]string) map[string]*regexp.Regexp {
+	compiled, err := compileFallbackPatterns(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return compiled
+}
+
+func htmlAttr(n *html.Node, name string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// Texto del nodo y sus hijos, sin scripts ni estilos; los bloques quedan en
+// líneas separadas como en innerText, para las expresiones de respaldo
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			switch n.Data {
+			case "script", "style":
+				return
+			case "br", "div", "p", "tr", "li", "table":
+				b.WriteByte('\n')
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// Subcomando -replay: extraer los resultados de las páginas .html guardadas
+// en dir (con -save-failed-html), sin navegador ni consultas a la DIAN. Si la página no trae la
+// cédula se usa el nombre del archivo (resultado_<cédula>.html)
+func runReplay(dir string, fallback map[string]*regexp.Regexp) ([]Result, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("error listando %s: %v", dir, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no hay archivos .html en %s", dir)
+	}
+	sort.Strings(files)
+
+	results := make([]Result, 0, len(files))
+	for _, file := range files {
+		page, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error leyendo %s: %v", file, err)
+		}
+		result := extractFromHTMLWith(page, fallback)
+		result.Source = filepath.Base(file)
+		if result.Cedula == "" {
+			result.Cedula = cedulaFromFilename(file)
+		}
+		log.Printf("Página %s: cédula %s, estado %s", result.Source, result.Cedula, result.Estado)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// "resultado_123456.html" -> "123456"
+func cedulaFromFilename(file string) string {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	if i := strings.LastIndex(name, "_"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
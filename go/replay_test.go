@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	page, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("error leyendo %s: %v", name, err)
+	}
+	return page
+}
+
+func TestExtractFromHTML(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Result
+	}{
+		{
+			fixture: "consulta.html",
+			want: Result{
+				Cedula:           "1012345678",
+				PrimerApellido:   "PEREZ",
+				SegundoApellido:  "GOMEZ",
+				PrimerNombre:     "JUAN",
+				SegundoNombre:    "CARLOS",
+				Estado:           "REGISTRO ACTIVO",
+				FechaInscripcion: "2015-03-05",
+			},
+		},
+		{
+			fixture: "detalle.html",
+			want: Result{
+				Cedula:           "900123456",
+				PrimerApellido:   "RODRIGUEZ",
+				PrimerNombre:     "ANA",
+				SegundoNombre:    "MARIA",
+				Estado:           "SUSPENSION",
+				FechaInscripcion: "2019-11-20",
+			},
+		},
+		{fixture: "sin_rut.html", want: Result{Estado: estadoSinRUT}},
+		{fixture: "error.html", want: Result{Estado: "Error", Error: "El código de verificación no es válido"}},
+		// Sin IDs conocidos se usan las expresiones de respaldo
+		{
+			fixture: "texto.html",
+			want: Result{
+				PrimerApellido:  "LOPEZ",
+				SegundoApellido: "DIAZ",
+				PrimerNombre:    "PEDRO",
+				Estado:          "REGISTRO ACTIVO",
+				ErrorCode:       errCodeFallback,
+			},
+		},
+		{fixture: "carga_lenta.html", want: Result{Estado: "Error", Error: "El HTML no tiene datos de la consulta"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got := extractFromHTML(readFixture(t, tt.fixture))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractFromHTML(%s) =\n%+v\nse esperaba\n%+v", tt.fixture, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCedulaFromFilename(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"resultado_1012345678.html", "1012345678"},
+		{"/tmp/paginas/resultado_0012345.html", "0012345"},
+		{"error_pagina_123.html", "123"},
+		{"123456.html", "123456"},
+	}
+	for _, tt := range tests {
+		if got := cedulaFromFilename(tt.file); got != tt.want {
+			t.Errorf("cedulaFromFilename(%q) = %q, se esperaba %q", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestRunReplay(t *testing.T) {
+	dir := t.TempDir()
+	for name, fixture := range map[string]string{
+		"resultado_555.html":        "consulta.html", // la página trae su propia cédula
+		"resultado_1023456789.html": "error.html",
+		"notas.txt":                 "datos.json",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), readFixture(t, fixture), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := runReplay(dir, defaultFallbackRegexps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type summary struct{ Source, Cedula, Estado string }
+	got := make([]summary, len(results))
+	for i, r := range results {
+		got[i] = summary{r.Source, r.Cedula, r.Estado}
+	}
+	want := []summary{
+		{"resultado_1023456789.html", "1023456789", "Error"},
+		{"resultado_555.html", "1012345678", "REGISTRO ACTIVO"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("runReplay = %+v, se esperaba %+v", got, want)
+	}
+
+	if _, err := runReplay(t.TempDir(), defaultFallbackRegexps); err == nil {
+		t.Error("runReplay sin páginas no devolvió error")
+	}
+}
+
+// La página de una consulta fallida queda guardada con -save-failed-html y
+// -replay obtiene de ella el mismo resultado
+func TestSaveFailedHTMLReplay(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+
+	tests := []struct {
+		name      string
+		escenario string
+		wantSaved bool
+	}{
+		{"consulta fallida", "error", true},
+		{"sin RUT", "sinrut", false},
+		{"consulta exitosa", "exito", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := browserTestConfig()
+			config.SaveFailedHTML = true
+			s := newBrowserScraper(t, config, srv, "escenario="+tt.escenario)
+
+			result := s.processCedula("1012345678", ctx, 1)
+			page, saved := config.ArtifactStore.(*memoryArtifactStore).get("resultado_1012345678.html")
+			if saved != tt.wantSaved {
+				t.Fatalf("HTML guardado = %v, se esperaba %v (Estado %q)", saved, tt.wantSaved, result.Estado)
+			}
+			if !saved {
+				return
+			}
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "resultado_1012345678.html"), page, 0644); err != nil {
+				t.Fatal(err)
+			}
+			replayed, err := runReplay(dir, defaultFallbackRegexps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := replayed[0]; got.Cedula != "1012345678" || got.Estado != result.Estado || got.Error != result.Error {
+				t.Errorf("replay = %q %q (%s), la consulta dio %q (%s)", got.Cedula, got.Estado, got.Error, result.Estado, result.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// Consultar solo la primera cédula, con un scraper aparte de un navegador,
+// para detectar una configuración rota antes de lanzar la ejecución completa.
+// Devuelve también el gasto en captchas de la prueba
+func runSmokeTest(config Config, input InputRecord) (Result, float64, error) {
+	smoke, err := newSmokeScraper(config)
+	if err != nil {
+		return Result{}, 0, err
+	}
+	defer smoke.Close()
+	return smokeTest(smoke, input)
+}
+
+func newSmokeScraper(config Config) (*Scraper, error) {
+	config.Sink = nil
+	config.ResultTransformer = nil
+	config.CaptchaPingbackURL = "" // El servidor de pingback es del scraper principal
+	config.Concurrency = 1
+	config.MaxParallelBrowsers = 1
+
+	smoke, err := NewScraper(config)
+	if err != nil {
+		return nil, fmt.Errorf("error inicializando scraper de prueba: %v", err)
+	}
+	return smoke, nil
+}
+
+func smokeTest(smoke *Scraper, input InputRecord) (Result, float64, error) {
+	log.Printf("Prueba previa con la cédula %s", input.Cedula)
+	results := smoke.ProcessInputs([]InputRecord{input})
+	spend := smoke.CaptchaSpend()
+	if len(results) == 0 {
+		return Result{}, spend, fmt.Errorf("la prueba con la cédula %s no devolvió resultado", input.Cedula)
+	}
+	result := results[0]
+	if result.Error != "" {
+		return result, spend, fmt.Errorf("la cédula %s falló: %s", input.Cedula, result.Error)
+	}
+	return result, spend, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// La prueba previa usa un solo navegador y no escribe en la salida del
+// scraper principal
+func TestNewSmokeScraper(t *testing.T) {
+	config := testConfig()
+	config.ScreenshotDir = t.TempDir()
+	config.Concurrency = 8
+	config.MaxParallelBrowsers = 4
+	config.Sink = &memorySink{}
+	config.ResultTransformer = func(r Result) (Result, bool) { return r, true }
+	config.CaptchaPingbackURL = "http://127.0.0.1:0/pingback"
+
+	smoke, err := newSmokeScraper(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smoke.Close()
+
+	got := smoke.config
+	if got.Concurrency != 1 || got.MaxParallelBrowsers != 1 {
+		t.Errorf("concurrencia %d y %d navegadores, se esperaba 1 y 1", got.Concurrency, got.MaxParallelBrowsers)
+	}
+	if got.Sink != nil || got.ResultTransformer != nil || got.CaptchaPingbackURL != "" {
+		t.Errorf("la prueba conserva sink, transformador o pingback del scraper principal")
+	}
+}
+
+func TestSmokeTest(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      func(string, int) Result
+		launchErr  error
+		wantErr    bool
+		wantEstado string
+	}{
+		{name: "cédula exitosa", query: okResult, wantEstado: "REGISTRO ACTIVO"},
+		{name: "cédula con error", query: errorResult, wantErr: true, wantEstado: "Error"},
+		// Una configuración rota falla antes de consultar
+		{name: "navegador no arranca", query: okResult, launchErr: errors.New("chrome no disponible"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var queried []string
+			s := newTestScraper(t, testConfig(), func(cedula string, attempt int) Result {
+				mu.Lock()
+				queried = append(queried, cedula)
+				mu.Unlock()
+				return tt.query(cedula, attempt)
+			})
+			if tt.launchErr != nil {
+				s.launch = func(context.Context) error { return tt.launchErr }
+			}
+
+			result, _, err := smokeTest(s, InputRecord{Cedula: "1012345678"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("smokeTest: error %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "1012345678") {
+				t.Errorf("el error %q no menciona la cédula", err)
+			}
+			if tt.wantEstado != "" && result.Estado != tt.wantEstado {
+				t.Errorf("Estado = %q, se esperaba %q", result.Estado, tt.wantEstado)
+			}
+			if len(queried) > 1 {
+				t.Errorf("se consultaron %d cédulas, la prueba consulta solo una", len(queried))
+			}
+		})
+	}
+}
+
+// El resultado de la prueba se reutiliza: la cédula no se consulta de nuevo
+func TestPreset(t *testing.T) {
+	var mu sync.Mutex
+	queried := make(map[string]int)
+	s := newTestScraper(t, testConfig(), func(cedula string, attempt int) Result {
+		mu.Lock()
+		queried[cedula]++
+		mu.Unlock()
+		return okResult(cedula, attempt)
+	})
+	s.Preset(Result{Cedula: "1000", Estado: "SUSPENDIDO"})
+
+	results := s.ProcessCedulas(testCedulas(3))
+	if len(results) != 3 {
+		t.Fatalf("%d resultados, se esperaban 3", len(results))
+	}
+	if queried["1000"] != 0 {
+		t.Errorf("la cédula de la prueba se consultó %d veces", queried["1000"])
+	}
+	if queried["1001"] != 1 || queried["1002"] != 1 {
+		t.Errorf("consultas = %v, se esperaba una por cada cédula restante", queried)
+	}
+	for _, result := range results {
+		if result.Cedula == "1000" && result.Estado != "SUSPENDIDO" {
+			t.Errorf("Estado de 1000 = %q, se esperaba el de la prueba", result.Estado)
+		}
+	}
+}
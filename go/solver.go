@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Formas de resolver el captcha de la DIAN con 2captcha
+const (
+	captchaMethodImage = "image" // Captura de la imagen
+	captchaMethodAudio = "audio" // Audio que ofrece la página, si lo hay
+)
+
+// Método de resolución con su costo estimado por envío (USD)
+type captchaMethod struct {
+	Name string
+	Cost float64
+}
+
+// Métodos habilitados en el orden configurado: CaptchaMethods o, por
+// defecto, la imagen y el audio si AudioCaptchaFallback está activo
+func (s *Scraper) enabledCaptchaMethods() []string {
+	if len(s.config.CaptchaMethods) > 0 {
+		return s.config.CaptchaMethods
+	}
+	methods := []string{captchaMethodImage}
+	if s.config.AudioCaptchaFallback {
+		methods = append(methods, captchaMethodAudio)
+	}
+	return methods
+}
+
+func (s *Scraper) captchaMethodCost(name string) float64 {
+	if name == captchaMethodAudio {
+		return s.config.AudioCaptchaCost
+	}
+	return s.config.CaptchaCost
+}
+
+// Métodos que sirven para la página actual, del más barato al más caro; a
+// igual costo se respeta el orden configurado
+func (s *Scraper) viableCaptchaMethods(ctx context.Context) []captchaMethod {
+	var methods []captchaMethod
+	for _, name := range s.enabledCaptchaMethods() {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case captchaMethodImage:
+		case captchaMethodAudio:
+			if s.config.AudioCaptchaSelector == "" || !elementExists(ctx, s.config.AudioCaptchaSelector) {
+				continue
+			}
+		default:
+			continue
+		}
+		methods = append(methods, captchaMethod{Name: name, Cost: s.captchaMethodCost(name)})
+	}
+	sort.SliceStable(methods, func(i, j int) bool { return methods[i].Cost < methods[j].Cost })
+	return methods
+}
+
+// Método para este intento: el primero usa el más barato y cada reintento
+// pasa al siguiente, quedándose en el último. Sin métodos viables se usa la imagen
+func (s *Scraper) pickCaptchaMethod(ctx context.Context, attempt int) captchaMethod {
+	methods := s.viableCaptchaMethods(ctx)
+	if len(methods) == 0 {
+		return captchaMethod{Name: captchaMethodImage, Cost: s.config.CaptchaCost}
+	}
+	idx := attempt - 1
+	if idx >= len(methods) {
+		idx = len(methods) - 1
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	return methods[idx]
+}
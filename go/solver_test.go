@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Método elegido en cada intento según el costo y lo que ofrece la página
+func TestPickCaptchaMethod(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFakeDIAN(t)
+
+	tests := []struct {
+		name      string
+		page      string
+		methods   []string
+		imageCost float64
+		audioCost float64
+		want      []string // método de los intentos 1, 2 y 3
+	}{
+		{
+			name:      "la imagen es más barata aunque se configure después",
+			page:      "captchaaudio",
+			methods:   []string{"audio", "image"},
+			imageCost: 0.001,
+			audioCost: 0.002,
+			want:      []string{"image", "audio", "audio"},
+		},
+		{
+			name:      "el audio es más barato",
+			page:      "captchaaudio",
+			methods:   []string{"image", "audio"},
+			imageCost: 0.003,
+			audioCost: 0.001,
+			want:      []string{"audio", "image", "image"},
+		},
+		{
+			name:      "a igual costo se respeta el orden",
+			page:      "captchaaudio",
+			methods:   []string{"audio", "image"},
+			imageCost: 0.001,
+			audioCost: 0.001,
+			want:      []string{"audio", "image", "image"},
+		},
+		{
+			name:      "página sin audio",
+			page:      "captcha",
+			methods:   []string{"audio", "image"},
+			imageCost: 0.001,
+			audioCost: 0.0001,
+			want:      []string{"image", "image", "image"},
+		},
+		{
+			name:      "métodos desconocidos se ignoran",
+			page:      "captchaaudio",
+			methods:   []string{"token", " AUDIO "},
+			imageCost: 0.001,
+			audioCost: 0.002,
+			want:      []string{"audio", "audio", "audio"},
+		},
+		{
+			name:      "sin métodos viables se usa la imagen",
+			page:      "captcha",
+			methods:   []string{"audio"},
+			imageCost: 0.001,
+			audioCost: 0.002,
+			want:      []string{"image", "image", "image"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := browserTestConfig()
+			config.CaptchaMethods = tt.methods
+			config.CaptchaCost = tt.imageCost
+			config.AudioCaptchaCost = tt.audioCost
+			s := newBrowserScraper(t, config, srv, "escenario="+tt.page)
+
+			tabCtx, cancel := chromedp.NewContext(ctx)
+			defer cancel()
+			if err := chromedp.Run(tabCtx, chromedp.Navigate(s.consultURL)); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for attempt := 1; attempt <= len(tt.want); attempt++ {
+				method := s.pickCaptchaMethod(tabCtx, attempt)
+				if method.Cost != s.captchaMethodCost(method.Name) {
+					t.Errorf("intento %d: costo %v para %s", attempt, method.Cost, method.Name)
+				}
+				got = append(got, method.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("métodos por intento = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
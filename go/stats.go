@@ -0,0 +1,163 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Contadores en vivo del procesamiento; el recolector los actualiza y se
+// pueden leer en cualquier momento (progreso, resumen)
+type Stats struct {
+	processed  atomic.Int64
+	successful atomic.Int64
+	errors     atomic.Int64
+	noData     atomic.Int64
+	attention  atomic.Int64
+
+	captchaRequired atomic.Int64
+
+	// Estados que cuentan como éxito; se fija antes de empezar a procesar
+	success successStates
+}
+
+// Copia de los contadores en un instante dado
+type RunStats struct {
+	Processed  int64 `json:"processed"`
+	Successful int64 `json:"successful"`
+	Errors     int64 `json:"errors"`
+	NoData     int64 `json:"noData"`
+	// Con datos pero con un estado fuera de SuccessStates (ej. SUSPENDIDO)
+	NeedsAttention int64 `json:"needsAttention"`
+
+	// Consultas en las que la DIAN pidió captcha
+	CaptchaRequired int64 `json:"captchaRequired"`
+}
+
+// Un estado con solo espacios cuenta como sin datos, igual que uno vacío
+func (st *Stats) record(result Result) {
+	st.processed.Add(1)
+	if result.CaptchaRequired {
+		st.captchaRequired.Add(1)
+	}
+	switch {
+	case st.success.successful(result):
+		st.successful.Add(1)
+	case result.Error != "":
+		st.errors.Add(1)
+	case strings.TrimSpace(result.Estado) != "":
+		st.attention.Add(1)
+	default:
+		st.noData.Add(1)
+	}
+}
+
+// Estados de la DIAN que cuentan como consulta exitosa, en mayúsculas. Vacío
+// = cualquier estado
+type successStates map[string]bool
+
+func newSuccessStates(states []string) successStates {
+	if len(states) == 0 {
+		return nil
+	}
+	set := make(successStates, len(states))
+	for _, state := range states {
+		if state = strings.ToUpper(strings.TrimSpace(state)); state != "" {
+			set[state] = true
+		}
+	}
+	return set
+}
+
+// Sin error y con un estado aceptado
+func (ss successStates) successful(result Result) bool {
+	estado := strings.TrimSpace(result.Estado)
+	if result.Error != "" || estado == "" {
+		return false
+	}
+	return len(ss) == 0 || ss[strings.ToUpper(estado)]
+}
+
+func (st *Stats) Snapshot() RunStats {
+	return RunStats{
+		Processed:  st.processed.Load(),
+		Successful: st.successful.Load(),
+		Errors:     st.errors.Load(),
+		NoData:     st.noData.Load(),
+
+		NeedsAttention: st.attention.Load(),
+
+		CaptchaRequired: st.captchaRequired.Load(),
+	}
+}
+
+// Contadores de un worker; se acumulan por índice, así que los reinicios del
+// navegador tras un panic siguen sumando en el mismo worker
+type WorkerStats struct {
+	Worker     int
+	Processed  int
+	Successful int
+	Errors     int
+	Captchas   int
+	Restarts   int
+	Busy       time.Duration // Tiempo consultando cédulas
+
+	// Cédula en consulta; vacío si el worker está libre
+	Current string
+}
+
+func (ws *WorkerStats) record(result Result, busy time.Duration, success successStates) {
+	ws.Processed++
+	ws.Current = ""
+	ws.Captchas += result.Captchas
+	ws.Busy += busy
+	if success.successful(result) {
+		ws.Successful++
+	} else if result.Error != "" {
+		ws.Errors++
+	}
+}
+
+// Tabla de contadores por worker, segura para uso concurrente
+type workerStatsTable struct {
+	mu      sync.Mutex
+	workers map[int]*WorkerStats
+}
+
+// Aplicar fn a los contadores del worker idx
+func (t *workerStatsTable) update(idx int, fn func(*WorkerStats)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.workers == nil {
+		t.workers = make(map[int]*WorkerStats)
+	}
+	ws, ok := t.workers[idx]
+	if !ok {
+		ws = &WorkerStats{Worker: idx}
+		t.workers[idx] = ws
+	}
+	fn(ws)
+}
+
+func (t *workerStatsTable) get(idx int) WorkerStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ws, ok := t.workers[idx]; ok {
+		return *ws
+	}
+	return WorkerStats{Worker: idx}
+}
+
+// Copia de todos los contadores, ordenada por worker
+func (t *workerStatsTable) snapshot() []WorkerStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]WorkerStats, 0, len(t.workers))
+	for _, ws := range t.workers {
+		out = append(out, *ws)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Worker < out[j].Worker })
+	return out
+}
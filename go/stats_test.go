@@ -0,0 +1,187 @@
+package main
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStatsRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		success []string
+		results []Result
+		want    RunStats
+	}{
+		{
+			name: "clasificación básica",
+			results: []Result{
+				{Estado: "REGISTRO ACTIVO"},
+				{Estado: "Error", Error: "timeout"},
+				{Estado: ""},
+				{Estado: "   "},
+			},
+			want: RunStats{Processed: 4, Successful: 1, Errors: 1, NoData: 2},
+		},
+		{
+			name: "pendientes cuentan como error",
+			results: []Result{
+				{Estado: "REGISTRO ACTIVO"},
+				{Estado: "Pendiente", Error: "No procesada: 20 errores consecutivos"},
+			},
+			want: RunStats{Processed: 2, Successful: 1, Errors: 1},
+		},
+		{
+			name: "captcha requerido",
+			results: []Result{
+				{Estado: "REGISTRO ACTIVO", CaptchaRequired: true},
+				{Estado: "REGISTRO ACTIVO"},
+			},
+			want: RunStats{Processed: 2, Successful: 2, CaptchaRequired: 1},
+		},
+		{
+			name: "sin resultados",
+			want: RunStats{},
+		},
+		{
+			name:    "estado fuera de los exitosos",
+			success: []string{"registro activo"},
+			results: []Result{
+				{Estado: "REGISTRO ACTIVO"},
+				{Estado: "SUSPENDIDO"},
+			},
+			want: RunStats{Processed: 2, Successful: 1, NeedsAttention: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var st Stats
+			st.success = newSuccessStates(tt.success)
+			for _, r := range tt.results {
+				st.record(r)
+			}
+			if got := st.Snapshot(); got != tt.want {
+				t.Errorf("Snapshot() = %+v, se esperaba %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuccessStates(t *testing.T) {
+	tests := []struct {
+		name   string
+		states []string
+		result Result
+		want   bool
+	}{
+		{"cualquier estado sin lista", nil, Result{Estado: "SUSPENDIDO"}, true},
+		{"estado en la lista", []string{"REGISTRO ACTIVO"}, Result{Estado: "REGISTRO ACTIVO"}, true},
+		{"sin distinguir mayúsculas ni espacios", []string{" registro activo "}, Result{Estado: "Registro Activo "}, true},
+		{"estado fuera de la lista", []string{"REGISTRO ACTIVO"}, Result{Estado: "SUSPENDIDO"}, false},
+		{"varios estados", []string{"REGISTRO ACTIVO", "SUSPENDIDO"}, Result{Estado: "SUSPENDIDO"}, true},
+		{"con error nunca", nil, Result{Estado: "REGISTRO ACTIVO", Error: "timeout"}, false},
+		{"estado vacío nunca", nil, Result{Estado: "  "}, false},
+		{"lista de solo espacios no restringe", []string{" ", ""}, Result{Estado: "SUSPENDIDO"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newSuccessStates(tt.states).successful(tt.result); got != tt.want {
+				t.Errorf("successful(%q) = %v, se esperaba %v", tt.result.Estado, got, tt.want)
+			}
+		})
+	}
+}
+
+// La lista de estados exitosos también cambia los contadores del scraper
+func TestScraperSuccessStates(t *testing.T) {
+	estados := map[string]string{"1000": "REGISTRO ACTIVO", "1001": "SUSPENDIDO", "1002": "REGISTRO ACTIVO", "1003": "CANCELADO"}
+	tests := []struct {
+		name           string
+		states         []string
+		wantSuccessful int64
+		wantAttention  int64
+	}{
+		{"sin lista", nil, 4, 0},
+		{"solo activos", []string{"REGISTRO ACTIVO"}, 2, 2},
+		{"activos y suspendidos", []string{"registro activo", "suspendido"}, 3, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.SuccessStates = tt.states
+			s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+				return Result{Estado: estados[cedula]}
+			})
+			s.ProcessCedulas(testCedulas(4))
+
+			got := s.Stats()
+			if got.Successful != tt.wantSuccessful || got.NeedsAttention != tt.wantAttention {
+				t.Errorf("exitosas %d, por revisar %d; se esperaban %d y %d",
+					got.Successful, got.NeedsAttention, tt.wantSuccessful, tt.wantAttention)
+			}
+		})
+	}
+}
+
+// Los contadores de los workers suman lo mismo que los de la ejecución
+func TestWorkerStatsSumToTotals(t *testing.T) {
+	tests := []struct {
+		name     string
+		browsers int
+		cedulas  int
+	}{
+		{"un worker", 1, 7},
+		{"varios workers", 3, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Concurrency = tt.browsers
+			config.MaxParallelBrowsers = tt.browsers
+			config.TimeoutConfig.MaxRetries = 1
+			s := newTestScraper(t, config, func(cedula string, attempt int) Result {
+				time.Sleep(5 * time.Millisecond) // Que todos los workers tomen cédulas
+				if n, _ := strconv.Atoi(cedula); n%3 == 0 {
+					return errorResult(cedula, attempt)
+				}
+				result := okResult(cedula, attempt)
+				result.Captchas = 1
+				return result
+			})
+
+			results := s.ProcessCedulas(testCedulas(tt.cedulas))
+			var sum WorkerStats
+			for _, ws := range s.WorkerStats() {
+				sum.Processed += ws.Processed
+				sum.Successful += ws.Successful
+				sum.Errors += ws.Errors
+				sum.Captchas += ws.Captchas
+				if ws.Processed > 0 && ws.Busy <= 0 {
+					t.Errorf("worker %d sin tiempo ocupado con %d cédulas", ws.Worker, ws.Processed)
+				}
+			}
+			// Los navegadores se limitan además al número de CPUs
+			want := tt.browsers
+			if n := runtime.NumCPU(); n < want {
+				want = n
+			}
+			if workers := len(s.WorkerStats()); workers != want {
+				t.Errorf("%d workers en la tabla, se esperaban %d", workers, want)
+			}
+
+			totals := s.Stats()
+			captchas := 0
+			for _, result := range results {
+				captchas += result.Captchas
+			}
+			if int64(sum.Processed) != totals.Processed || int64(sum.Successful) != totals.Successful ||
+				int64(sum.Errors) != totals.Errors || sum.Captchas != captchas {
+				t.Errorf("suma por worker %+v; totales %+v con %d captchas", sum, totals, captchas)
+			}
+			if sum.Processed != tt.cedulas {
+				t.Errorf("%d cédulas procesadas por los workers, se esperaban %d", sum.Processed, tt.cedulas)
+			}
+		})
+	}
+}
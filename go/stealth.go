@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// Script que oculta las señales más comunes de automatización antes de que
+// corra cualquier script de la página: navigator.webdriver, plugins vacíos,
+// idiomas y el objeto window.chrome que falta en Chrome controlado por CDP
+const stealthScript = `(() => {
+	Object.defineProperty(Navigator.prototype, 'webdriver', { get: () => undefined });
+	Object.defineProperty(navigator, 'languages', { get: () => ['es-CO', 'es', 'en'] });
+	Object.defineProperty(navigator, 'plugins', {
+		get: () => [
+			{ name: 'PDF Viewer', filename: 'internal-pdf-viewer', description: 'Portable Document Format' },
+			{ name: 'Chrome PDF Viewer', filename: 'internal-pdf-viewer', description: 'Portable Document Format' },
+		],
+	});
+	if (!window.chrome) {
+		window.chrome = { runtime: {} };
+	}
+	const query = window.navigator.permissions && window.navigator.permissions.query;
+	if (query) {
+		window.navigator.permissions.query = (params) => params && params.name === 'notifications'
+			? Promise.resolve({ state: Notification.permission })
+			: query(params);
+	}
+})();`
+
+// Registrar stealthScript en la pestaña para todos los documentos nuevos
+func stealthAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if _, err := page.AddScriptToEvaluateOnNewDocument(stealthScript).Do(ctx); err != nil {
+			return fmt.Errorf("error registrando script stealth: %v", err)
+		}
+		return nil
+	})
+}
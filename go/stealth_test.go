@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Señales de automatización que ve un script de la página
+type automationSignals struct {
+	Webdriver bool     `json:"webdriver"`
+	Languages []string `json:"languages"`
+	Plugins   int      `json:"plugins"`
+	Chrome    bool     `json:"chrome"`
+}
+
+func TestStealth(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFixtureServer(t)
+
+	tests := []struct {
+		name    string
+		stealth bool
+	}{
+		{"con stealth", true},
+		{"sin stealth", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Stealth = tt.stealth
+			s := newTestScraper(t, config, nil)
+
+			tabCtx, cancel := chromedp.NewContext(ctx)
+			defer cancel()
+			var got automationSignals
+			err := chromedp.Run(tabCtx,
+				s.browserProfile(),
+				chromedp.Navigate(srv.URL+"/consulta.html"),
+				chromedp.Evaluate(`({
+					webdriver: navigator.webdriver === true,
+					languages: Array.from(navigator.languages),
+					plugins: navigator.plugins.length,
+					chrome: typeof window.chrome === 'object',
+				})`, &got),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.stealth {
+				// Sin el script Chrome controlado por CDP se delata
+				if !got.Webdriver {
+					t.Errorf("navigator.webdriver = false sin stealth; la prueba no distingue el script")
+				}
+				return
+			}
+			want := automationSignals{Webdriver: false, Languages: []string{"es-CO", "es", "en"}, Plugins: 2, Chrome: true}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("señales = %+v, se esperaba %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Contadores calculados a partir de un conjunto de resultados ya completo
+func computeRunStats(results []Result, success successStates) RunStats {
+	st := Stats{success: success}
+	for _, result := range results {
+		st.record(result)
+	}
+	return st.Snapshot()
+}
+
+// Conteo por valor, ordenado de mayor a menor
+type countEntry struct {
+	Value string
+	Count int
+}
+
+func countBy(results []Result, key func(Result) string) []countEntry {
+	counts := make(map[string]int)
+	for _, result := range results {
+		if value := key(result); value != "" {
+			counts[value]++
+		}
+	}
+
+	entries := make([]countEntry, 0, len(counts))
+	for value, count := range counts {
+		entries = append(entries, countEntry{value, count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Value < entries[j].Value
+	})
+	return entries
+}
+
+// Duraciones de las consultas que registraron tiempo de procesamiento
+func processingTimes(results []Result) []time.Duration {
+	times := make([]time.Duration, 0, len(results))
+	for _, result := range results {
+		if d, ok := parseProcessingTime(result.ProcessingTime); ok {
+			times = append(times, d)
+		}
+	}
+	return times
+}
+
+// Percentiles (0-100) por rango más cercano: el valor en la posición
+// ceil(p/100*n) de los tiempos ordenados. Sin tiempos devuelve ceros
+func percentiles(times []time.Duration, ps ...float64) []time.Duration {
+	out := make([]time.Duration, len(ps))
+	if len(times) == 0 {
+		return out
+	}
+	sorted := append([]time.Duration(nil), times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, p := range ps {
+		rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		}
+		if rank > len(sorted) {
+			rank = len(sorted)
+		}
+		out[i] = sorted[rank-1]
+	}
+	return out
+}
+
+// Percentiles que se reportan en el resumen
+var summaryPercentiles = []float64{50, 90, 99}
+
+// Hoja de resumen: totales, conteo por estado y por código de error, tiempos
+// y, si se conocen, los contadores de cada worker
+func writeSummarySheet(f *excelize.File, sheet string, results []Result, opts OutputOptions) {
+	row := 1
+	set := func(label string, value interface{}) {
+		f.SetCellValue(sheet, fmt.Sprintf("A%d", row), label)
+		if value != nil {
+			f.SetCellValue(sheet, fmt.Sprintf("B%d", row), value)
+		}
+		row++
+	}
+
+	stats := computeRunStats(results, newSuccessStates(opts.SuccessStates))
+	set("Resumen", nil)
+	set("Total", stats.Processed)
+	set("Exitosas", stats.Successful)
+	set("Con error", stats.Errors)
+	set("Sin datos", stats.NoData)
+	set("Requieren revisión", stats.NeedsAttention)
+	set("Con captcha", stats.CaptchaRequired)
+	row++
+
+	set("Por estado", nil)
+	for _, entry := range countBy(results, func(r Result) string { return r.Estado }) {
+		set(entry.Value, entry.Count)
+	}
+	row++
+
+	set("Por código de error", nil)
+	for _, entry := range countBy(results, func(r Result) string { return r.ErrorCode }) {
+		set(entry.Value, entry.Count)
+	}
+	row++
+
+	if workers := opts.WorkerStats; len(workers) > 0 {
+		writeWorkerTable(f, sheet, row, workers)
+		row += len(workers) + 2
+	}
+
+	times := processingTimes(results)
+	set("Tiempos (segundos)", nil)
+	if len(times) == 0 {
+		return
+	}
+	var total, minTime, maxTime time.Duration
+	minTime = times[0]
+	for _, d := range times {
+		total += d
+		if d < minTime {
+			minTime = d
+		}
+		if d > maxTime {
+			maxTime = d
+		}
+	}
+	set("Promedio", (total / time.Duration(len(times))).Seconds())
+	set("Mínimo", minTime.Seconds())
+	set("Máximo", maxTime.Seconds())
+	for i, d := range percentiles(times, summaryPercentiles...) {
+		set(fmt.Sprintf("p%g", summaryPercentiles[i]), d.Seconds())
+	}
+}
+
+// Tabla por worker a partir de la fila row
+func writeWorkerTable(f *excelize.File, sheet string, row int, workers []WorkerStats) {
+	headers := []interface{}{"Worker", "Procesadas", "Exitosas", "Con error", "Captchas", "Reinicios", "Ocupado (segundos)"}
+	f.SetSheetRow(sheet, fmt.Sprintf("A%d", row), &headers)
+	for i, ws := range workers {
+		values := []interface{}{ws.Worker, ws.Processed, ws.Successful, ws.Errors, ws.Captchas, ws.Restarts, ws.Busy.Seconds()}
+		f.SetSheetRow(sheet, fmt.Sprintf("A%d", row+i+1), &values)
+	}
+}
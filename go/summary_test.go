@@ -0,0 +1,140 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestCountBy(t *testing.T) {
+	results := []Result{
+		{Estado: "REGISTRO ACTIVO"},
+		{Estado: "Error", ErrorCode: errCodeRateLimited},
+		{Estado: "REGISTRO ACTIVO"},
+		{Estado: "Error", ErrorCode: errCodeCaptchaLimit},
+		{Estado: "SUSPENDIDO"},
+		{Estado: ""},
+	}
+	tests := []struct {
+		name string
+		key  func(Result) string
+		want []countEntry
+	}{
+		{
+			name: "por estado, mayor a menor y luego alfabético",
+			key:  func(r Result) string { return r.Estado },
+			want: []countEntry{{"Error", 2}, {"REGISTRO ACTIVO", 2}, {"SUSPENDIDO", 1}},
+		},
+		{
+			name: "omite valores vacíos",
+			key:  func(r Result) string { return r.ErrorCode },
+			want: []countEntry{{errCodeCaptchaLimit, 1}, {errCodeRateLimited, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countBy(results, tt.key); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("countBy = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteSummarySheet(t *testing.T) {
+	results := []Result{
+		{Cedula: "1", Estado: "REGISTRO ACTIVO", ProcessingTime: "2s"},
+		{Cedula: "2", Estado: "REGISTRO ACTIVO", ProcessingTime: "4s"},
+		{Cedula: "3", Estado: "Error", Error: "timeout", ErrorCode: errCodeRateLimited, ProcessingTime: "6s"},
+		{Cedula: "4", Estado: ""},
+	}
+	path := filepath.Join(t.TempDir(), "resultados.xlsx")
+	if err := writeResultsToExcel(path, results, OutputOptions{SummarySheet: true}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if first := f.GetSheetName(0); first != summarySheetName {
+		t.Errorf("primera hoja = %q, se esperaba %q", first, summarySheetName)
+	}
+	rows, err := f.GetRows(summarySheetName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells := make(map[string]string)
+	for _, row := range rows {
+		if len(row) >= 2 {
+			cells[row[0]] = row[1]
+		}
+	}
+
+	tests := []struct {
+		label string
+		want  string
+	}{
+		{"Total", "4"},
+		{"Exitosas", "2"},
+		{"Con error", "1"},
+		{"Sin datos", "1"},
+		{"REGISTRO ACTIVO", "2"},
+		{errCodeRateLimited, "1"},
+		{"Promedio", "4"},
+		{"Mínimo", "2"},
+		{"Máximo", "6"},
+		{"p50", "4"},
+		{"p90", "6"},
+		{"p99", "6"},
+	}
+	for _, tt := range tests {
+		if got := cells[tt.label]; got != tt.want {
+			t.Errorf("celda %q = %q, se esperaba %q", tt.label, got, tt.want)
+		}
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	// 1ms, 2ms, ..., 100ms en orden inverso
+	hundred := make([]time.Duration, 100)
+	for i := range hundred {
+		hundred[i] = time.Duration(100-i) * time.Millisecond
+	}
+	ms := func(values ...int) []time.Duration {
+		out := make([]time.Duration, len(values))
+		for i, v := range values {
+			out[i] = time.Duration(v) * time.Millisecond
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		times []time.Duration
+		ps    []float64
+		want  []time.Duration
+	}{
+		{"distribución uniforme", hundred, []float64{50, 90, 99}, ms(50, 90, 99)},
+		{"extremos", hundred, []float64{0, 100}, ms(1, 100)},
+		{"un solo tiempo", ms(7), []float64{50, 90, 99}, ms(7, 7, 7)},
+		// Rango más cercano: ceil(p/100*n), sin interpolar
+		{"pocos tiempos", ms(2, 6, 4), []float64{50, 90}, ms(4, 6)},
+		{"cola larga", ms(1, 1, 1, 1, 1, 1, 1, 1, 1, 30), []float64{50, 90, 99}, ms(1, 1, 30)},
+		{"sin tiempos", nil, []float64{50, 90, 99}, ms(0, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := append([]time.Duration(nil), tt.times...)
+			if got := percentiles(tt.times, tt.ps...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("percentiles(%v) = %v, se esperaba %v", tt.ps, got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.times, before) {
+				t.Error("percentiles modificó los tiempos recibidos")
+			}
+		})
+	}
+}
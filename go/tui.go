@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Vista de progreso en la terminal (-tui). El modelo solo cambia con
+// update, a partir de eventos, y view lo dibuja; así el estado no depende
+// de la terminal
+const (
+	tuiRefresh      = 250 * time.Millisecond
+	tuiRecentErrors = 5
+	tuiBarWidth     = 40
+)
+
+// Eventos que recibe el modelo
+type tuiEvent interface{}
+
+// Resultado de una cédula, tal como llega por Results()
+type tuiResultEvent struct {
+	Result Result
+}
+
+// Contadores actuales de los workers
+type tuiWorkersEvent struct {
+	Workers []WorkerStats
+}
+
+// Fin del procesamiento
+type tuiDoneEvent struct{}
+
+type tuiModel struct {
+	total   int // Cédulas esperadas; cero si no se conoce (-stream-input)
+	stats   Stats
+	workers []WorkerStats
+	errors  []string // Últimos errores, el más reciente al final
+	started time.Time
+	done    bool
+}
+
+func newTUIModel(total int, success successStates, started time.Time) *tuiModel {
+	return &tuiModel{total: total, stats: Stats{success: success}, started: started}
+}
+
+func (m *tuiModel) update(ev tuiEvent) {
+	switch ev := ev.(type) {
+	case tuiResultEvent:
+		m.stats.record(ev.Result)
+		if ev.Result.Error != "" {
+			m.errors = append(m.errors, fmt.Sprintf("%s: %s", ev.Result.Cedula, ev.Result.Error))
+			if len(m.errors) > tuiRecentErrors {
+				m.errors = m.errors[len(m.errors)-tuiRecentErrors:]
+			}
+		}
+	case tuiWorkersEvent:
+		m.workers = ev.Workers
+	case tuiDoneEvent:
+		m.done = true
+	}
+}
+
+func (m *tuiModel) view(now time.Time) string {
+	stats := m.stats.Snapshot()
+	var b strings.Builder
+
+	title := "Procesando cédulas"
+	if m.done {
+		title = "Procesamiento terminado"
+	}
+	fmt.Fprintf(&b, "%s (%v)\n\n", title, now.Sub(m.started).Round(time.Second))
+
+	if m.total > 0 {
+		fmt.Fprintf(&b, "%s %d/%d\n", progressBar(int(stats.Processed), m.total, tuiBarWidth), stats.Processed, m.total)
+	} else {
+		fmt.Fprintf(&b, "%d procesadas\n", stats.Processed)
+	}
+	fmt.Fprintf(&b, "Exitosas: %d  Con error: %d  Sin datos: %d  Requieren revisión: %d\n\n",
+		stats.Successful, stats.Errors, stats.NoData, stats.NeedsAttention)
+
+	for _, ws := range m.workers {
+		status := "libre"
+		if ws.Current != "" {
+			status = "consultando " + ws.Current
+		}
+		fmt.Fprintf(&b, "Worker %-3d %-26s %d procesadas, %d con error, %d captchas\n",
+			ws.Worker, status, ws.Processed, ws.Errors, ws.Captchas)
+	}
+
+	if len(m.errors) > 0 {
+		b.WriteString("\nÚltimos errores:\n")
+		for _, e := range m.errors {
+			fmt.Fprintf(&b, "  %s\n", e)
+		}
+	}
+	return b.String()
+}
+
+// Barra [#####.....] de width caracteres
+func progressBar(done, total, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = done * width / total
+	}
+	if filled > width {
+		filled = width
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
+
+// Si f es una terminal (y no un archivo o una tubería)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Dibujar el progreso en out hasta que se cierre Results(). Debe iniciarse
+// antes de procesar; el canal devuelto se cierra tras el último dibujo
+func runTUI(s *Scraper, total int, out io.Writer) <-chan struct{} {
+	results := s.Results()
+	done := make(chan struct{})
+	model := newTUIModel(total, s.stats.success, time.Now())
+
+	draw := func() {
+		// Volver al inicio y limpiar la pantalla antes de cada dibujo
+		fmt.Fprint(out, "\033[H\033[2J"+model.view(time.Now()))
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(tuiRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case result, ok := <-results:
+				if !ok {
+					model.update(tuiWorkersEvent{s.WorkerStats()})
+					model.update(tuiDoneEvent{})
+					draw()
+					return
+				}
+				model.update(tuiResultEvent{result})
+			case <-ticker.C:
+				model.update(tuiWorkersEvent{s.WorkerStats()})
+				draw()
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressBar(t *testing.T) {
+	tests := []struct {
+		done, total, width int
+		want               string
+	}{
+		{0, 10, 10, "[..........]"},
+		{5, 10, 10, "[#####.....]"},
+		{10, 10, 10, "[##########]"},
+		{1, 3, 6, "[##....]"},
+		// Más procesadas que las esperadas (reintentos contados dos veces)
+		{12, 10, 10, "[##########]"},
+		{3, 0, 4, "[....]"},
+	}
+	for _, tt := range tests {
+		if got := progressBar(tt.done, tt.total, tt.width); got != tt.want {
+			t.Errorf("progressBar(%d, %d, %d) = %q, se esperaba %q", tt.done, tt.total, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestTUIModelUpdate(t *testing.T) {
+	failed := func(cedula string) tuiEvent {
+		return tuiResultEvent{Result{Cedula: cedula, Estado: "Error", Error: "timeout"}}
+	}
+	tests := []struct {
+		name       string
+		events     []tuiEvent
+		wantStats  RunStats
+		wantErrors []string
+		wantDone   bool
+	}{
+		{
+			name: "resultados",
+			events: []tuiEvent{
+				tuiResultEvent{Result{Cedula: "1", Estado: "REGISTRO ACTIVO"}},
+				tuiResultEvent{Result{Cedula: "2", Estado: "SUSPENDIDO"}},
+				failed("3"),
+				tuiResultEvent{Result{Cedula: "4"}},
+			},
+			wantStats:  RunStats{Processed: 4, Successful: 1, Errors: 1, NoData: 1, NeedsAttention: 1},
+			wantErrors: []string{"3: timeout"},
+		},
+		{
+			name:       "solo los últimos errores",
+			events:     []tuiEvent{failed("1"), failed("2"), failed("3"), failed("4"), failed("5"), failed("6"), failed("7")},
+			wantStats:  RunStats{Processed: 7, Errors: 7},
+			wantErrors: []string{"3: timeout", "4: timeout", "5: timeout", "6: timeout", "7: timeout"},
+		},
+		{
+			name:     "fin",
+			events:   []tuiEvent{tuiDoneEvent{}},
+			wantDone: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTUIModel(10, newSuccessStates([]string{"REGISTRO ACTIVO"}), time.Now())
+			for _, ev := range tt.events {
+				m.update(ev)
+			}
+			if got := m.stats.Snapshot(); got != tt.wantStats {
+				t.Errorf("estadísticas = %+v, se esperaba %+v", got, tt.wantStats)
+			}
+			if !reflect.DeepEqual(m.errors, tt.wantErrors) {
+				t.Errorf("errores = %q, se esperaba %q", m.errors, tt.wantErrors)
+			}
+			if m.done != tt.wantDone {
+				t.Errorf("done = %v, se esperaba %v", m.done, tt.wantDone)
+			}
+		})
+	}
+}
+
+func TestTUIModelView(t *testing.T) {
+	started := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		total  int
+		events []tuiEvent
+		want   []string
+	}{
+		{
+			name:  "en curso",
+			total: 4,
+			events: []tuiEvent{
+				tuiResultEvent{Result{Cedula: "1", Estado: "REGISTRO ACTIVO"}},
+				tuiResultEvent{Result{Cedula: "2", Estado: "Error", Error: "timeout"}},
+				tuiWorkersEvent{[]WorkerStats{{Worker: 0, Current: "3", Processed: 2, Errors: 1}, {Worker: 1}}},
+			},
+			want: []string{
+				"Procesando cédulas (1m30s)",
+				"[" + strings.Repeat("#", 20) + strings.Repeat(".", 20) + "] 2/4",
+				"Exitosas: 1  Con error: 1",
+				"consultando 3",
+				"libre",
+				"Últimos errores:\n  2: timeout",
+			},
+		},
+		{
+			name:   "total desconocido",
+			events: []tuiEvent{tuiResultEvent{Result{Cedula: "1", Estado: "REGISTRO ACTIVO"}}},
+			want:   []string{"1 procesadas"},
+		},
+		{
+			name:   "terminado",
+			total:  1,
+			events: []tuiEvent{tuiResultEvent{Result{Cedula: "1", Estado: "REGISTRO ACTIVO"}}, tuiDoneEvent{}},
+			want:   []string{"Procesamiento terminado"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTUIModel(tt.total, nil, started)
+			for _, ev := range tt.events {
+				m.update(ev)
+			}
+			view := m.view(started.Add(90 * time.Second))
+			for _, want := range tt.want {
+				if !strings.Contains(view, want) {
+					t.Errorf("la vista no contiene %q:\n%s", want, view)
+				}
+			}
+		})
+	}
+}
+
+// runTUI dibuja hasta que termina el procesamiento
+func TestRunTUI(t *testing.T) {
+	s := newTestScraper(t, testConfig(), okResult)
+	var out bytes.Buffer
+	done := runTUI(s, 3, &out)
+	s.ProcessCedulas(testCedulas(3))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("la vista no terminó al cerrarse los resultados")
+	}
+	frames := strings.Split(out.String(), "\033[H\033[2J")
+	last := frames[len(frames)-1]
+	for _, want := range []string{"Procesamiento terminado", "3/3", "Exitosas: 3"} {
+		if !strings.Contains(last, want) {
+			t.Errorf("el último dibujo no contiene %q:\n%s", want, last)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// Reabrir el archivo de resultados y confirmar que tiene expected filas. El
+// formato se deduce de la extensión
+func verifyOutput(path string, expected int) error {
+	return verifyOutputFormat(path, outputFormat(path, ""), expected)
+}
+
+func verifyOutputFormat(path, format string, expected int) error {
+	var rows int
+	var err error
+	switch format {
+	case "xlsx":
+		rows, err = countExcelRows(path)
+	case "jsonl":
+		rows, err = countJSONLRows(path)
+	case "parquet":
+		rows, err = countParquetRows(path)
+	default:
+		return fmt.Errorf("formato de salida no soportado: %s", format)
+	}
+	if err != nil {
+		return fmt.Errorf("error verificando %s: %v", path, err)
+	}
+	if rows != expected {
+		return fmt.Errorf("el archivo %s tiene %d filas, se esperaban %d", path, rows, expected)
+	}
+	return nil
+}
+
+// Filas de la hoja de resultados, sin el encabezado
+func countExcelRows(path string) (int, error) {
+	f, err := openWithRetry(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	sheet := f.GetSheetName(0)
+	if idx, _ := f.GetSheetIndex("Results"); idx >= 0 {
+		sheet = "Results"
+	}
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("la hoja %s no tiene encabezado", sheet)
+	}
+	return len(rows) - 1, nil
+}
+
+// Cada línea no vacía debe ser un JSON válido; una línea cortada a medias
+// indica un archivo truncado
+func countJSONLRows(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	rows := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if !json.Valid(scanner.Bytes()) {
+			return rows, fmt.Errorf("línea %d no es un JSON válido", line)
+		}
+		rows++
+	}
+	return rows, scanner.Err()
+}
+
+// Número de filas según el pie del archivo Parquet
+func countParquetRows(path string) (int, error) {
+	fr, err := local.NewLocalFileReader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer fr.Close()
+
+	pr, err := reader.NewParquetReader(fr, nil, 1)
+	if err != nil {
+		return 0, err
+	}
+	defer pr.ReadStop()
+	return int(pr.GetNumRows()), nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Cortar el archivo a la mitad, como una escritura interrumpida
+func truncateHalf(t *testing.T, path string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyOutput(t *testing.T) {
+	results := []Result{
+		{Cedula: "1", Estado: "REGISTRO ACTIVO", PrimerNombre: "JUAN"},
+		{Cedula: "2", Estado: "REGISTRO CANCELADO", PrimerNombre: "ANA"},
+		{Cedula: "3", Error: "timeout"},
+	}
+	writers := map[string]func(string) error{
+		"xlsx":    func(path string) error { return writeResultsToExcel(path, results, OutputOptions{SummarySheet: true}) },
+		"jsonl":   func(path string) error { return writeResultsToJSONL(path, results, nil) },
+		"parquet": func(path string) error { return writeResultsToParquet(path, results) },
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		truncate bool
+		expected int
+		wantErr  bool
+	}{
+		{"excel correcto", "xlsx", false, 3, false},
+		{"excel con filas de menos", "xlsx", false, 4, true},
+		{"excel truncado", "xlsx", true, 3, true},
+		{"jsonl correcto", "jsonl", false, 3, false},
+		{"jsonl con filas de más", "jsonl", false, 2, true},
+		{"jsonl truncado", "jsonl", true, 3, true},
+		{"parquet correcto", "parquet", false, 3, false},
+		{"parquet truncado", "parquet", true, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "resultados."+tt.format)
+			if err := writers[tt.format](path); err != nil {
+				t.Fatal(err)
+			}
+			if tt.truncate {
+				truncateHalf(t, path)
+			}
+
+			err := verifyOutput(path, tt.expected)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyOutput = %v, se esperaba error: %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := verifyOutputFormat(filepath.Join(t.TempDir(), "r.csv"), "csv", 0); err == nil {
+		t.Error("se esperaba error con un formato no soportado")
+	}
+}
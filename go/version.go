@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Se inyectan al compilar:
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=abc123 -X main.buildDate=2024-06-01"
+var (
+	version   = ""
+	commit    = ""
+	buildDate = ""
+)
+
+// Describir la versión en ejecución; sin ldflags se usa la información que
+// Go incrusta en el binario (versión del módulo y datos de VCS)
+func buildInfo() string {
+	v, c, d := version, commit, buildDate
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" {
+			v = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if c == "" {
+					c = setting.Value
+				}
+			case "vcs.time":
+				if d == "" {
+					d = setting.Value
+				}
+			}
+		}
+	}
+
+	if v == "" {
+		v = "(devel)"
+	}
+	if c == "" {
+		c = "desconocido"
+	}
+	if d == "" {
+		d = "desconocida"
+	}
+	return fmt.Sprintf("dian-scrapper %s (commit %s, compilado %s)", v, c, d)
+}
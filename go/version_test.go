@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildInfo(t *testing.T) {
+	tests := []struct {
+		name                string
+		ver, rev, date      string
+		wantPrefix, wantSub string
+	}{
+		{"sin ldflags", "", "", "", "dian-scrapper ", "(commit "},
+		{"con ldflags", "1.2.0", "abc123", "2024-06-01", "dian-scrapper 1.2.0 ", "(commit abc123, compilado 2024-06-01)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(v, c, d string) { version, commit, buildDate = v, c, d }(version, commit, buildDate)
+			version, commit, buildDate = tt.ver, tt.rev, tt.date
+
+			got := buildInfo()
+			if !strings.HasPrefix(got, tt.wantPrefix) || !strings.Contains(got, tt.wantSub) {
+				t.Errorf("buildInfo() = %q", got)
+			}
+			// Sin ldflags ningún dato queda vacío
+			if strings.Contains(got, "  ") || strings.Contains(got, "commit ,") || strings.HasSuffix(got, "compilado )") {
+				t.Errorf("buildInfo() con datos vacíos: %q", got)
+			}
+		})
+	}
+}
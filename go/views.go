@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Vista JSF en la que quedó la página tras hacer clic en Buscar
+type ViewType string
+
+const (
+	viewUnknown ViewType = ""
+	viewConsult ViewType = "consulta" // formulario de consulta con el panel de resultados
+	viewDetail  ViewType = "detalle"  // página de detalle, con otros IDs de formulario
+)
+
+// Prefijo de los IDs del formulario de consulta
+const consultFormPrefix = "vistaConsultaEstadoRUT:formConsultaEstadoRUT:"
+
+// Selectores XPath de los datos en una vista
+type viewSelectors struct {
+	NumNit          string
+	PrimerApellido  string
+	SegundoApellido string
+	PrimerNombre    string
+	OtrosNombres    string
+	Estado          string
+}
+
+// Selectores por vista. La vista de detalle no tiene un prefijo fijo: se
+// buscan los elementos cuyo ID termina en el nombre del campo
+var viewSelectorSets = map[ViewType]viewSelectors{
+	viewConsult: {
+		NumNit:          consultFieldXPath("numNit"),
+		PrimerApellido:  consultFieldXPath("primerApellido"),
+		SegundoApellido: consultFieldXPath("segundoApellido"),
+		PrimerNombre:    consultFieldXPath("primerNombre"),
+		OtrosNombres:    consultFieldXPath("otrosNombres"),
+		Estado:          consultFieldXPath("estado"),
+	},
+	viewDetail: {
+		NumNit:          idSuffixXPath("numNit"),
+		PrimerApellido:  idSuffixXPath("primerApellido"),
+		SegundoApellido: idSuffixXPath("segundoApellido"),
+		PrimerNombre:    idSuffixXPath("primerNombre"),
+		OtrosNombres:    idSuffixXPath("otrosNombres"),
+		Estado:          idSuffixXPath("estado"),
+	},
+}
+
+func consultFieldXPath(field string) string {
+	return fmt.Sprintf(`//*[@id="%s%s"]`, consultFormPrefix, field)
+}
+
+// Primer elemento cuyo ID termina en ":field" (XPath 1.0 no tiene ends-with)
+func idSuffixXPath(field string) string {
+	suffix := ":" + field
+	return fmt.Sprintf(`(//*[substring(@id, string-length(@id) - %d) = %q])[1]`, len(suffix)-1, suffix)
+}
+
+// Selectores de la vista; una vista desconocida usa los de la consulta
+func selectorsForView(view ViewType) viewSelectors {
+	if sel, ok := viewSelectorSets[view]; ok {
+		return sel
+	}
+	return viewSelectorSets[viewConsult]
+}
+
+// Identificar la vista por los IDs presentes: los campos del formulario de
+// consulta, o campos de datos con otro prefijo (detalle)
+func detectView(ctx context.Context) ViewType {
+	expr := fmt.Sprintf(`(() => {
+		if (document.getElementById(%q)) return %q;
+		if (document.querySelector('[id$=":primerApellido"], [id$=":estado"]')) return %q;
+		return '';
+	})()`, consultFormPrefix+"primerApellido", viewConsult, viewDetail)
+	var view string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(expr, &view)); err != nil {
+		return viewUnknown
+	}
+	return ViewType(view)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chromedp/chromedp"
+)
+
+func TestSelectorsForView(t *testing.T) {
+	tests := []struct {
+		view ViewType
+		want string // selector de primerApellido
+	}{
+		{viewConsult, `//*[@id="vistaConsultaEstadoRUT:formConsultaEstadoRUT:primerApellido"]`},
+		{viewDetail, `(//*[substring(@id, string-length(@id) - 14) = ":primerApellido"])[1]`},
+		// Una vista desconocida usa los selectores de la consulta
+		{viewUnknown, `//*[@id="vistaConsultaEstadoRUT:formConsultaEstadoRUT:primerApellido"]`},
+	}
+	for _, tt := range tests {
+		if got := selectorsForView(tt.view).PrimerApellido; got != tt.want {
+			t.Errorf("selectorsForView(%q).PrimerApellido = %s, se esperaba %s", tt.view, got, tt.want)
+		}
+	}
+}
+
+// Cada vista se reconoce y sus selectores encuentran los datos
+func TestDetectView(t *testing.T) {
+	ctx := newTestBrowser(t)
+	srv := newFixtureServer(t)
+
+	tests := []struct {
+		page         string
+		want         ViewType
+		wantApellido string
+		wantEstado   string
+	}{
+		{"consulta.html", viewConsult, "PEREZ", "REGISTRO ACTIVO"},
+		{"detalle.html", viewDetail, "RODRIGUEZ", "SUSPENSION"},
+		{"error.html", viewUnknown, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			tabCtx, cancel := chromedp.NewContext(ctx)
+			defer cancel()
+			if err := chromedp.Run(tabCtx, chromedp.Navigate(srv.URL+"/"+tt.page)); err != nil {
+				t.Fatal(err)
+			}
+
+			view := detectView(tabCtx)
+			if view != tt.want {
+				t.Fatalf("detectView = %q, se esperaba %q", view, tt.want)
+			}
+			if view == viewUnknown {
+				return
+			}
+			sel := selectorsForView(view)
+			for _, field := range []struct{ xpath, want string }{
+				{sel.PrimerApellido, tt.wantApellido},
+				{sel.Estado, tt.wantEstado},
+			} {
+				// Los datos están en campos de texto o en spans según la vista
+				var got string
+				expr := fmt.Sprintf(`(() => {
+					const el = document.evaluate(%q, document, null, XPathResult.FIRST_ORDERED_NODE_TYPE, null).singleNodeValue;
+					return el ? (el.value || el.textContent).trim() : '';
+				})()`, field.xpath)
+				if err := chromedp.Run(tabCtx, chromedp.Evaluate(expr, &got)); err != nil {
+					t.Fatal(err)
+				}
+				if got != field.want {
+					t.Errorf("%s = %q, se esperaba %q", field.xpath, got, field.want)
+				}
+			}
+		})
+	}
+}